@@ -0,0 +1,375 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+    "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// The detail screen charts the reply times and loss of a single host over a
+// selectable time window. The chart has one column per time bucket: bars of
+// block characters show the average reply time of the rounds in the bucket,
+// dashed lines mark the window's min/avg/max/p95, and a strip below the chart
+// shows loss. A cursor can be moved over the columns to read the rounds
+// behind each one.
+
+// chartWindow is a selectable time span of the detail chart.
+type chartWindow struct {
+    label string
+    span  time.Duration
+}
+
+// chartWindows lists the windows in the order the number keys select them.
+var chartWindows = []chartWindow{
+    {"5m", 5 * time.Minute},
+    {"1h", time.Hour},
+    {"24h", 24 * time.Hour},
+}
+
+// chartBucket aggregates the samples that fall into one chart column.
+type chartBucket struct {
+    from, to   time.Time
+    rounds     int
+    sent, recv int
+    timed      int
+    sum        float64 // of reply times, for the average
+    last       sample  // latest sample in the bucket
+}
+
+// avg returns the average reply time of the bucket, or -1 if none.
+func (b chartBucket) avg() float64 {
+    if b.timed == 0 {
+        return -1
+    }
+    return b.sum / float64(b.timed)
+}
+
+// loss returns the fraction of probes in the bucket that went unanswered.
+func (b chartBucket) loss() float64 {
+    if b.sent == 0 {
+        return 0
+    }
+    return float64(b.sent-b.recv) / float64(b.sent)
+}
+
+// windowStats summarises all samples of the charted window.
+type windowStats struct {
+    rounds             int
+    sent, recv         int
+    timed              int
+    min, avg, max, p95 float64
+}
+
+// chartData splits the window ending at now into cols buckets and collects
+// the samples of h into them. Windows that reach back further than the
+// ring of single rounds are charted from the per‑minute aggregates instead;
+// their p95 is then taken over the minutes' averages.
+func chartData(h *history, span time.Duration, now time.Time, cols int) ([]chartBucket, windowStats) {
+    buckets := make([]chartBucket, cols)
+    from := now.Add(-span)
+    step := span / time.Duration(cols)
+    for i := range buckets {
+        buckets[i].from = from.Add(time.Duration(i) * step)
+        buckets[i].to = buckets[i].from.Add(step)
+    }
+    var st windowStats
+    if h == nil {
+        return buckets, st
+    }
+    var rtts []float64
+    var sum float64
+    // add counts rounds taken at t into their bucket and the window; last
+    // stands in for them on the cursor line.
+    add := func(t time.Time, last sample, rounds, sent, recv, timed int, rttSum, lo, hi float64) {
+        k := min(int(t.Sub(from)/step), cols-1)
+        b := &buckets[k]
+        if b.rounds == 0 {
+            b.last = last
+        }
+        b.rounds += rounds
+        b.sent += sent
+        b.recv += recv
+        st.rounds += rounds
+        st.sent += sent
+        st.recv += recv
+        if timed == 0 {
+            return
+        }
+        b.timed += timed
+        b.sum += rttSum
+        if st.timed == 0 || lo < st.min {
+            st.min = lo
+        }
+        st.max = max(st.max, hi)
+        st.timed += timed
+        sum += rttSum
+        rtts = append(rtts, rttSum/float64(timed))
+    }
+    // Walk back from the newest sample or minute until the window starts.
+    if h.covers(from) {
+        for i := h.len() - 1; i >= 0; i-- {
+            s := h.at(i)
+            t := s.time()
+            if t.Before(from) {
+                break
+            }
+            timed, rtt := 0, float64(s.rtt)
+            if s.rtt >= 0 && !s.lost() {
+                timed = 1
+            }
+            add(t, s, 1, int(s.sent), int(s.recv), timed, rtt, rtt, rtt)
+        }
+    } else {
+        for i := h.minuteLen() - 1; i >= 0; i-- {
+            a := h.minuteAt(i)
+            t := a.time()
+            if t.Before(from) {
+                break
+            }
+            add(t, sample{at: t.UnixMilli()}, int(a.rounds), int(a.sent), int(a.recv), int(a.timed),
+                float64(a.sum), float64(a.min), float64(a.max))
+        }
+    }
+    if len(rtts) > 0 {
+        sort.Float64s(rtts)
+        st.avg = sum / float64(st.timed)
+        st.p95 = percentile(rtts, 95)
+    }
+    return buckets, st
+}
+
+// openDetail switches to the detail screen for the selected host.
+func (m *model) openDetail() {
+    if len(m.hosts) == 0 {
+        return
+    }
+    m.detailID = m.hosts[m.cursor].ID
+    m.detailOffset = 0
+    m.mode = modeDetail
+}
+
+// updateDetail handles keys on the detail screen: the arrow keys scrub,
+// the number keys (or w) pick the window and Esc, Enter or q go back.
+func (m model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    switch msg.String() {
+    case "ctrl+c":
+        m.quitting = true
+        return m, tea.Quit
+    case "esc", "enter", "q", "Q":
+        m.mode = modeList
+    case "left", "h":
+        m.detailOffset++
+    case "right", "l":
+        m.detailOffset--
+    case "shift+left", "pgup":
+        m.detailOffset += 10
+    case "shift+right", "pgdown":
+        m.detailOffset -= 10
+    case "home":
+        m.detailOffset = math.MaxInt32
+    case "end":
+        m.detailOffset = 0
+    case "w", "W", "tab":
+        m.detailWindow = (m.detailWindow + 1) % len(chartWindows)
+    default:
+        for i := range chartWindows {
+            if msg.String() == fmt.Sprint(i+1) {
+                m.detailWindow = i
+            }
+        }
+    }
+    // The offset counts columns from the right edge and is clamped when the
+    // chart is drawn, as the width may change in between.
+    if m.detailOffset < 0 {
+        m.detailOffset = 0
+    }
+    if cols := m.chartCols(); m.detailOffset > cols-1 {
+        m.detailOffset = cols - 1
+    }
+    return m, nil
+}
+
+// chartAxisWidth is the width of the reply time labels left of the chart.
+const chartAxisWidth = 9
+
+// chartCols returns the number of chart columns that fit the terminal.
+func (m model) chartCols() int {
+    width := m.width
+    if width == 0 {
+        width = 80
+    }
+    return max(width-chartAxisWidth-2, 10)
+}
+
+// detailView renders the detail screen.
+func (m model) detailView() string {
+    i := m.hostIndex(m.detailID)
+    if i < 0 {
+        return "Host no longer exists. Press Esc to go back.\n"
+    }
+    h := m.hosts[i]
+    res := m.results[h.ID]
+    win := chartWindows[m.detailWindow]
+    cols := m.chartCols()
+    rows := max(m.height-11, 4)
+    now := m.now()
+    buckets, st := chartData(m.history[h.ID], win.span, now, cols)
+    cur := cols - 1 - min(m.detailOffset, cols-1)
+
+    titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Bold(true)
+    dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+    barStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+    lossStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+    cursorStyle := lipgloss.NewStyle().Background(lipgloss.Color("8"))
+    overlays := []struct {
+        name  string
+        value float64
+        style lipgloss.Style
+    }{
+        {"min", st.min, lipgloss.NewStyle().Foreground(lipgloss.Color("6"))},
+        {"avg", st.avg, lipgloss.NewStyle().Foreground(lipgloss.Color("3"))},
+        {"max", st.max, lipgloss.NewStyle().Foreground(lipgloss.Color("1"))},
+        {"p95", st.p95, lipgloss.NewStyle().Foreground(lipgloss.Color("5"))},
+    }
+
+    var out strings.Builder
+    // Title: the host, its state and why, and how often it changed lately.
+    title := h.Host
+    if h.Desc != "" {
+        title += " — " + h.Desc
+    }
+    out.WriteString(titleStyle.Render(title) + "   " + res.statusText())
+    if reason := res.reasonOnly(); reason != "" {
+        out.WriteString("   " + reason)
+    }
+    out.WriteString("\n")
+    out.WriteString(dimStyle.Render(fmt.Sprintf("state changes in the last %.0fm: %d", flapWindow.Minutes(), len(res.changes))) + "\n\n")
+
+    // Window selector and summary with the overlay legend.
+    var tabs []string
+    for k, w := range chartWindows {
+        label := fmt.Sprintf(" %d %s ", k+1, w.label)
+        if k == m.detailWindow {
+            label = lipgloss.NewStyle().Reverse(true).Render(label)
+        }
+        tabs = append(tabs, label)
+    }
+    summary := "no data in this window"
+    if st.rounds > 0 {
+        var parts []string
+        if st.timed > 0 {
+            for _, o := range overlays {
+                parts = append(parts, o.style.Render(fmt.Sprintf("┄ %s %.1f", o.name, o.value)))
+            }
+        }
+        parts = append(parts, fmt.Sprintf("loss %.1f%%", 100*float64(st.sent-st.recv)/float64(max(st.sent, 1))))
+        parts = append(parts, fmt.Sprintf("%d rounds", st.rounds))
+        summary = strings.Join(parts, "   ")
+    }
+    out.WriteString(strings.Join(tabs, " ") + "   " + summary + "\n")
+
+    // The chart itself, top row first. The scale leaves some headroom above
+    // the maximum so its line doesn't stick to the top edge.
+    top := st.max * 1.1
+    if top <= 0 {
+        top = 1
+    }
+    // Where lines share a row, the later overlay wins.
+    overlayRow := make(map[int]int) // chart row -> index into overlays
+    if st.timed > 0 {
+        for k := range overlays {
+            r := rows - 1 - int(overlays[k].value/top*float64(rows))
+            overlayRow[min(max(r, 0), rows-1)] = k
+        }
+    }
+    for r := 0; r < rows; r++ {
+        label := ""
+        switch r {
+        case 0:
+            label = fmt.Sprintf("%.1f", top)
+        case rows / 2:
+            label = fmt.Sprintf("%.1f", top/2)
+        case rows - 1:
+            label = "0 ms"
+        }
+        out.WriteString(dimStyle.Render(fmt.Sprintf("%*s │", chartAxisWidth-2, label)))
+        level := rows - 1 - r // rows counted from the bottom
+        for c, b := range buckets {
+            cell := " "
+            style := barStyle
+            switch v := b.avg(); {
+            case b.rounds > 0 && b.recv == 0:
+                if level == 0 {
+                    cell, style = string(sparkLost), lossStyle
+                }
+            case v >= 0:
+                eighths := int(v/top*float64(rows*8)) - level*8
+                switch {
+                case eighths >= 8:
+                    cell = "█"
+                case eighths > 0:
+                    cell = string(sparkBlocks[eighths-1])
+                }
+            }
+            if k, ok := overlayRow[r]; ok && cell == " " {
+                cell, style = "┄", overlays[k].style
+            }
+            if c == cur {
+                style = style.Inherit(cursorStyle)
+            }
+            out.WriteString(style.Render(cell))
+        }
+        out.WriteString("\n")
+    }
+    // Loss strip: the higher the block, the more probes were lost.
+    out.WriteString(dimStyle.Render(fmt.Sprintf("%*s │", chartAxisWidth-2, "loss")))
+    for c, b := range buckets {
+        cell := " "
+        if l := b.loss(); l > 0 {
+            cell = string(sparkBlocks[int(l*float64(len(sparkBlocks)-1))])
+        }
+        style := lossStyle
+        if c == cur {
+            style = style.Inherit(cursorStyle)
+        }
+        out.WriteString(style.Render(cell))
+    }
+    out.WriteString("\n")
+    // Time axis: start of the window on the left, now on the right.
+    layout := "15:04:05"
+    if win.span > 12*time.Hour {
+        layout = "Mon 15:04"
+    }
+    start := buckets[0].from.Format(layout)
+    end := "now"
+    pad := max(cols-len(start)-len(end), 1)
+    out.WriteString(strings.Repeat(" ", chartAxisWidth) + dimStyle.Render(start+strings.Repeat(" ", pad)+end) + "\n\n")
+
+    // The rounds under the cursor.
+    b := buckets[cur]
+    var info string
+    switch {
+    case b.rounds == 0:
+        info = fmt.Sprintf("%s–%s  no data", b.from.Format(layout), b.to.Format(layout))
+    case b.rounds == 1:
+        rtt := "no reply"
+        if b.timed > 0 {
+            rtt = fmt.Sprintf("%.1f ms", b.avg())
+        }
+        info = fmt.Sprintf("%s  %s  %d/%d answered", b.last.time().Format("15:04:05"), rtt, b.recv, b.sent)
+    default:
+        rtt := "no reply"
+        if b.timed > 0 {
+            rtt = fmt.Sprintf("avg %.1f ms", b.avg())
+        }
+        info = fmt.Sprintf("%s–%s  %s  loss %.0f%%  (%d rounds)", b.from.Format(layout), b.to.Format(layout), rtt, 100*b.loss(), b.rounds)
+    }
+    out.WriteString(strings.Repeat(" ", chartAxisWidth) + info + "\n")
+    out.WriteString(dimStyle.Render("←/→ Scrub   Home/End Oldest/Latest   1/2/3 or W Window   Esc Back") + "\n")
+    return out.String()
+}
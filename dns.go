@@ -0,0 +1,150 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "math/rand"
+    "net"
+    "net/url"
+    "strings"
+    "time"
+
+    "golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsTypes lists the record types a dns:// target may query.
+var dnsTypes = map[string]dnsmessage.Type{
+    "A":     dnsmessage.TypeA,
+    "AAAA":  dnsmessage.TypeAAAA,
+    "CNAME": dnsmessage.TypeCNAME,
+    "MX":    dnsmessage.TypeMX,
+    "NS":    dnsmessage.TypeNS,
+    "PTR":   dnsmessage.TypePTR,
+    "SOA":   dnsmessage.TypeSOA,
+    "SRV":   dnsmessage.TypeSRV,
+    "TXT":   dnsmessage.TypeTXT,
+}
+
+// dnsQuery describes the query sent by a dns:// probe.
+type dnsQuery struct {
+    server string // host part of the target, an IP or a name
+    port   string
+    name   dnsmessage.Name
+    typ    dnsmessage.Type
+}
+
+// parseDNSTarget parses dns://server[:port]?name=example.org&type=AAAA. The
+// name defaults to the root zone and the type to NS, which any recursive
+// resolver can answer.
+func parseDNSTarget(target string) (dnsQuery, error) {
+    u, err := url.Parse(target)
+    if err != nil {
+        return dnsQuery{}, err
+    }
+    q := dnsQuery{server: u.Hostname(), port: u.Port(), typ: dnsmessage.TypeNS}
+    if q.port == "" {
+        q.port = "53"
+    }
+    name := u.Query().Get("name")
+    if name == "" {
+        name = "."
+    }
+    if !strings.HasSuffix(name, ".") {
+        name += "."
+    }
+    if q.name, err = dnsmessage.NewName(name); err != nil {
+        return dnsQuery{}, fmt.Errorf("invalid query name %q", name)
+    }
+    if t := u.Query().Get("type"); t != "" {
+        typ, ok := dnsTypes[strings.ToUpper(t)]
+        if !ok {
+            return dnsQuery{}, fmt.Errorf("unsupported record type %q", t)
+        }
+        q.typ = typ
+    }
+    return q, nil
+}
+
+// rcodeName returns the conventional mnemonic for a response code, e.g.
+// NXDOMAIN rather than dnsmessage's RCodeNameError.
+func rcodeName(rc dnsmessage.RCode) string {
+    switch rc {
+    case dnsmessage.RCodeSuccess:
+        return "NOERROR"
+    case dnsmessage.RCodeFormatError:
+        return "FORMERR"
+    case dnsmessage.RCodeServerFailure:
+        return "SERVFAIL"
+    case dnsmessage.RCodeNameError:
+        return "NXDOMAIN"
+    case dnsmessage.RCodeNotImplemented:
+        return "NOTIMP"
+    case dnsmessage.RCodeRefused:
+        return "REFUSED"
+    }
+    return fmt.Sprintf("RCODE%d", rc)
+}
+
+// probeDNSQuery sends a single query over UDP to the server of a dns:// target.
+// The host is up when the server answers with NOERROR; the reply time is the
+// time from sending the query to receiving the response, and the INFO column
+// shows the rcode and number of answers.
+func probeDNSQuery(target string, fam addrFamily) pingResult {
+    q, err := parseDNSTarget(target)
+    if err != nil {
+        return failed(failOther, "invalid target", "")
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+    defer cancel()
+    ip, err := resolveFor(ctx, q.server, fam)
+    if err != nil {
+        return failedErr(err, "")
+    }
+    id := uint16(rand.Intn(1 << 16))
+    msg := dnsmessage.Message{
+        Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+        Questions: []dnsmessage.Question{{Name: q.name, Type: q.typ, Class: dnsmessage.ClassINET}},
+    }
+    packet, err := msg.Pack()
+    if err != nil {
+        return failed(failOther, "invalid target", "")
+    }
+    var d net.Dialer
+    conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(ip.String(), q.port))
+    if err != nil {
+        return failedErr(err, "")
+    }
+    defer conn.Close()
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+    start := time.Now()
+    if _, err := conn.Write(packet); err != nil {
+        return failedErr(err, "")
+    }
+    buf := make([]byte, 4096)
+    for {
+        n, err := conn.Read(buf)
+        if err != nil {
+            return failedErr(err, "")
+        }
+        elapsed := time.Since(start)
+        var p dnsmessage.Parser
+        hdr, err := p.Start(buf[:n])
+        if err != nil || hdr.ID != id || !hdr.Response {
+            // Not our response; keep waiting until the deadline.
+            continue
+        }
+        p.SkipAllQuestions()
+        answers, _ := p.AllAnswers()
+        rcode := rcodeName(hdr.RCode)
+        info := fmt.Sprintf("%s %d answers", rcode, len(answers))
+        if hdr.Truncated {
+            info += " (truncated)"
+        }
+        if hdr.RCode != dnsmessage.RCodeSuccess {
+            return failed(failCheck, rcode, info)
+        }
+        return pingResult{status: true, reply: float64(elapsed.Microseconds()) / 1000, info: info}
+    }
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/charmbracelet/bubbles/textinput"
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// Export writes the current status of the hosts and their recorded rounds as
+// CSV or JSON, for spreadsheets and notebooks. It is available as the
+// "mping export" subcommand and from the X dialog of the dashboard. Both
+// formats share one row layout; the record field tells current status rows
+// ("status") from recorded rounds ("round") and the per‑minute summaries of
+// compacted days ("minute").
+
+// exportRow is one exported row.
+type exportRow struct {
+    Record string    `json:"record"`
+    Time   time.Time `json:"time"`
+    Host   string    `json:"host"`
+    Status string    `json:"status"`
+    RTT    *float64  `json:"rtt_ms"`   // nil without a reply time
+    Loss   *float64  `json:"loss_pct"` // nil if nothing was sent
+    Sent   int       `json:"sent"`
+    Recv   int       `json:"recv"`
+    Rounds int       `json:"rounds"`
+    Reason string    `json:"reason,omitempty"`
+}
+
+// exportColumns are the CSV columns, in the order of exportRow.
+var exportColumns = []string{"record", "time", "host", "status", "rtt_ms", "loss_pct", "sent", "recv", "rounds", "reason"}
+
+// exportFilter selects the hosts and time range to export. Hosts match if
+// their host column contains host, ignoring case. A zero to means now.
+type exportFilter struct {
+    host     string
+    from, to time.Time
+}
+
+// match reports whether the host with the given spec is exported.
+func (f exportFilter) match(key string) bool {
+    return f.host == "" || strings.Contains(strings.ToLower(key), strings.ToLower(f.host))
+}
+
+// lossOf returns the loss percentage of sent and recv probes, or nil.
+func lossOf(sent, recv int) *float64 {
+    if sent == 0 {
+        return nil
+    }
+    loss := 100 * float64(sent-recv) / float64(sent)
+    return &loss
+}
+
+// rowOf converts a round or summary record into an exported row. State
+// changes are not exported separately; they show in the rounds.
+func rowOf(r record) (exportRow, bool) {
+    row := exportRow{Time: r.at, Host: r.key, Sent: r.sent, Recv: r.recv, Loss: lossOf(r.sent, r.recv)}
+    switch r.kind {
+    case recRound:
+        row.Record, row.Status, row.Rounds, row.Reason = "round", r.state.String(), 1, r.reason
+        if r.rtt >= 0 {
+            rtt := r.rtt
+            row.RTT = &rtt
+        }
+    case recAggregate:
+        // A minute counts as up if most of its rounds were
+        row.Record, row.Rounds = "minute", r.rounds
+        row.Status = stateDown.String()
+        if 2*r.up >= r.rounds {
+            row.Status = stateUp.String()
+        }
+        if r.timed > 0 {
+            rtt := r.rttSum / float64(r.timed)
+            row.RTT = &rtt
+        }
+    default:
+        return exportRow{}, false
+    }
+    return row, true
+}
+
+// statusRow converts a host's current result into an exported row.
+func statusRow(at time.Time, h Host, res pingResult) exportRow {
+    row := exportRow{Record: "status", Time: at, Host: h.spec(), Status: res.statusText(),
+        Sent: res.stats.sent, Recv: res.stats.recv, Loss: lossOf(res.stats.sent, res.stats.recv),
+        Reason: res.reasonText()}
+    if res.state != statePending {
+        row.Rounds = 1
+    }
+    if res.stats.timed > 0 {
+        rtt := res.stats.avg
+        row.RTT = &rtt
+    }
+    return row
+}
+
+// scanFunc reads records taken in [from, to), like store.scan.
+type scanFunc func(from, to time.Time, fn func(record) bool) error
+
+// exportHistory collects the recorded rounds selected by f, oldest first.
+func exportHistory(scan scanFunc, f exportFilter, now time.Time) ([]exportRow, error) {
+    to := f.to
+    if to.IsZero() || to.After(now) {
+        to = now.Add(time.Millisecond)
+    }
+    var rows []exportRow
+    err := scan(f.from, to, func(r record) bool {
+        if !f.match(r.key) {
+            return true
+        }
+        if row, ok := rowOf(r); ok {
+            rows = append(rows, row)
+        }
+        return true
+    })
+    sort.SliceStable(rows, func(i, j int) bool { return rows[i].Time.Before(rows[j].Time) })
+    return rows, err
+}
+
+// latestRows returns the last row of every host as its status, for exports
+// made without a running dashboard.
+func latestRows(rows []exportRow) []exportRow {
+    last := make(map[string]int)
+    var hosts []string
+    for i, row := range rows {
+        if _, ok := last[row.Host]; !ok {
+            hosts = append(hosts, row.Host)
+        }
+        last[row.Host] = i
+    }
+    sort.Strings(hosts)
+    status := make([]exportRow, 0, len(hosts))
+    for _, host := range hosts {
+        row := rows[last[host]]
+        row.Record = "status"
+        status = append(status, row)
+    }
+    return status
+}
+
+// exportFormat returns the format to write: format if given, otherwise JSON
+// for a .json file name and CSV for any other. Only a format given
+// explicitly can be unknown.
+func exportFormat(format, path string) (string, error) {
+    if format == "" {
+        if strings.EqualFold(filepath.Ext(path), ".json") {
+            return "json", nil
+        }
+        return "csv", nil
+    }
+    switch strings.ToLower(format) {
+    case "csv":
+        return "csv", nil
+    case "json":
+        return "json", nil
+    }
+    return "", fmt.Errorf("unknown format %q, want csv or json", format)
+}
+
+// writeExport writes the status rows followed by the history rows to w.
+func writeExport(w io.Writer, format string, at time.Time, status, history []exportRow) error {
+    if format == "json" {
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(struct {
+            ExportedAt time.Time   `json:"exported_at"`
+            Status     []exportRow `json:"status"`
+            History    []exportRow `json:"history"`
+        }{at, status, history})
+    }
+    cw := csv.NewWriter(w)
+    cw.Write(exportColumns)
+    num := func(v *float64) string {
+        if v == nil {
+            return ""
+        }
+        return strconv.FormatFloat(*v, 'f', 3, 64)
+    }
+    for _, rows := range [][]exportRow{status, history} {
+        for _, row := range rows {
+            cw.Write([]string{row.Record, row.Time.Format("2006-01-02T15:04:05.000Z07:00"), row.Host, row.Status,
+                num(row.RTT), num(row.Loss), strconv.Itoa(row.Sent), strconv.Itoa(row.Recv),
+                strconv.Itoa(row.Rounds), row.Reason})
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// writeExportFile writes an export to path, or to standard output for "-"
+// or "".
+func writeExportFile(path, format string, at time.Time, status, history []exportRow) error {
+    if path == "" || path == "-" {
+        return writeExport(os.Stdout, format, at, status, history)
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := writeExport(f, format, at, status, history); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// parseWhen parses a point in time for the export range: empty for no
+// limit, "now", a duration back from now such as "90m", "24h" or "7d", or a
+// local date and time such as "2026-01-31 23:00" or "2026-01-31".
+func parseWhen(s string, now time.Time) (time.Time, error) {
+    s = strings.TrimSpace(s)
+    switch s {
+    case "":
+        return time.Time{}, nil
+    case "now":
+        return now, nil
+    }
+    if days, ok := strings.CutSuffix(s, "d"); ok {
+        if n, err := strconv.ParseFloat(days, 64); err == nil {
+            return now.Add(-time.Duration(n * 24 * float64(time.Hour))), nil
+        }
+    }
+    if d, err := time.ParseDuration(s); err == nil {
+        return now.Add(-d), nil
+    }
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+        if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("invalid time %q, want e.g. 24h, 7d or 2006-01-02 15:04", s)
+}
+
+// runExport implements "mping export".
+func runExport(args []string) {
+    fs := flag.NewFlagSet("export", flag.ExitOnError)
+    fs.Usage = func() {
+        fmt.Fprintln(fs.Output(), "usage: mping export [flags]")
+        fmt.Fprintln(fs.Output(), "Writes the last recorded status and the recorded rounds of each host as CSV or JSON.")
+        fs.PrintDefaults()
+    }
+    format := fs.String("format", "", "csv or json (default json for a .json -o file, else csv)")
+    host := fs.String("host", "", "only hosts whose host column contains this text")
+    from := fs.String("from", "24h", "start of the range: a duration back from now (90m, 7d) or a date and time")
+    to := fs.String("to", "now", "end of the range, like -from")
+    out := fs.String("o", "-", "output file, - for standard output")
+    dir := fs.String("data", dataDir, "data directory")
+    fs.Parse(args)
+    if fs.NArg() > 0 {
+        fs.Usage()
+        os.Exit(2)
+    }
+    now := time.Now()
+    f := exportFilter{host: *host}
+    var err error
+    if f.from, err = parseWhen(*from, now); err == nil {
+        f.to, err = parseWhen(*to, now)
+    }
+    fail := func(err error) {
+        fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+        os.Exit(1)
+    }
+    if err != nil {
+        fail(err)
+    }
+    fmtName, err := exportFormat(*format, *out)
+    if err != nil {
+        fail(err)
+    }
+    scan := func(from, to time.Time, fn func(record) bool) error { return scanDir(*dir, from, to, fn) }
+    history, err := exportHistory(scan, f, now)
+    if err != nil {
+        fail(err)
+    }
+    if err := writeExportFile(*out, fmtName, now, latestRows(history), history); err != nil {
+        fail(err)
+    }
+}
+
+// exportInputs returns the text inputs of the export dialog in focus order.
+func (m *model) exportInputs() []*textinput.Model {
+    return []*textinput.Model{&m.exportHost, &m.exportFrom, &m.exportTo, &m.exportFile}
+}
+
+// openExport opens the export dialog with the last day of all hosts.
+func (m *model) openExport() {
+    m.exportHost = textinput.New()
+    m.exportHost.Placeholder = "Only hosts containing (empty = all)"
+    m.exportFrom = textinput.New()
+    m.exportFrom.Placeholder = "From, e.g. 24h, 7d or 2026-01-31 23:00"
+    m.exportFrom.SetValue("24h")
+    m.exportTo = textinput.New()
+    m.exportTo.Placeholder = "To (empty = now)"
+    m.exportFile = textinput.New()
+    m.exportFile.Placeholder = "File, .csv or .json"
+    m.exportFile.SetValue("mping-export-" + m.now().Format("20060102-150405") + ".csv")
+    m.exportFocus = 0
+    m.exportHost.Focus()
+    m.mode = modeExport
+}
+
+// updateExport handles keys in the export dialog: Tab moves between the
+// fields, Enter moves on and writes the file from the last field, Esc
+// cancels.
+func (m model) updateExport(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    inputs := m.exportInputs()
+    switch msg.String() {
+    case "ctrl+c":
+        m.quitting = true
+        return m, tea.Quit
+    case "esc":
+        m.mode = modeList
+        return m, nil
+    case "tab", "shift+tab":
+        inputs[m.exportFocus].Blur()
+        if msg.String() == "tab" {
+            m.exportFocus = (m.exportFocus + 1) % len(inputs)
+        } else {
+            m.exportFocus = (m.exportFocus + len(inputs) - 1) % len(inputs)
+        }
+        inputs[m.exportFocus].Focus()
+        return m, nil
+    case "enter":
+        if m.exportFocus < len(inputs)-1 {
+            inputs[m.exportFocus].Blur()
+            m.exportFocus++
+            inputs[m.exportFocus].Focus()
+            return m, nil
+        }
+        cmd, err := m.export()
+        if err != nil {
+            m.setMessage("Export failed: " + err.Error())
+            return m, nil
+        }
+        m.mode = modeList
+        return m, cmd
+    }
+    var cmd tea.Cmd
+    *inputs[m.exportFocus], cmd = inputs[m.exportFocus].Update(msg)
+    return m, cmd
+}
+
+// exportDoneMsg reports the outcome of an export started from the dialog.
+type exportDoneMsg struct {
+    path  string
+    hosts int // status rows written
+    rows  int // history rows written
+    err   error
+}
+
+// export starts writing the file described by the export dialog: the
+// current status of the matching hosts and their recorded rounds in the
+// chosen range. Reading the store can take a while for a long range, so the
+// rounds are read and the file written by the returned command, which
+// reports with an exportDoneMsg; the dashboard and probes carry on meanwhile.
+// Invalid input is reported right away.
+func (m *model) export() (tea.Cmd, error) {
+    if m.exporting != "" {
+        return nil, fmt.Errorf("still exporting to %s", m.exporting)
+    }
+    now := m.now()
+    f := exportFilter{host: strings.TrimSpace(m.exportHost.Value())}
+    var err error
+    if f.from, err = parseWhen(m.exportFrom.Value(), now); err != nil {
+        return nil, err
+    }
+    if f.to, err = parseWhen(m.exportTo.Value(), now); err != nil {
+        return nil, err
+    }
+    path := strings.TrimSpace(m.exportFile.Value())
+    if path == "" || path == "-" {
+        return nil, fmt.Errorf("no file name")
+    }
+    format, err := exportFormat("", path)
+    if err != nil {
+        return nil, err
+    }
+    var status []exportRow
+    for _, h := range m.hosts {
+        if f.match(h.spec()) {
+            status = append(status, statusRow(now, h, m.results[h.ID]))
+        }
+    }
+    var scan scanFunc
+    switch {
+    case m.replay != nil:
+        // A copy, as the replay clock moves on during the export
+        rp := *m.replay
+        scan = rp.scan
+    case m.store != nil:
+        scan = m.store.scan
+    default:
+        scan = func(time.Time, time.Time, func(record) bool) error { return nil }
+    }
+    m.exporting = path
+    m.setMessage("Exporting to " + path + "…")
+    return func() tea.Msg {
+        history, err := exportHistory(scan, f, now)
+        if err == nil {
+            err = writeExportFile(path, format, now, status, history)
+        }
+        return exportDoneMsg{path: path, hosts: len(status), rows: len(history), err: err}
+    }, nil
+}
+
+// exportDone reports a finished export in the message line.
+func (m *model) exportDone(msg exportDoneMsg) {
+    m.exporting = ""
+    if msg.err != nil {
+        m.setMessage("Export to " + msg.path + " failed: " + msg.err.Error())
+        return
+    }
+    m.setMessage(fmt.Sprintf("Exported %d hosts and %d rounds to %s", msg.hosts, msg.rows, msg.path))
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParseWhen(t *testing.T) {
+    now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+    tests := []struct {
+        in   string
+        want time.Time
+        ok   bool
+    }{
+        {"", time.Time{}, true},
+        {"now", now, true},
+        {" now ", now, true},
+        {"90m", now.Add(-90 * time.Minute), true},
+        {"24h", now.Add(-24 * time.Hour), true},
+        {"7d", now.Add(-7 * 24 * time.Hour), true},
+        {"1.5d", now.Add(-36 * time.Hour), true},
+        {"2026-01-31", time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local), true},
+        {"2026-01-31 23:00", time.Date(2026, 1, 31, 23, 0, 0, 0, time.Local), true},
+        {"2026-01-31 23:00:30", time.Date(2026, 1, 31, 23, 0, 30, 0, time.Local), true},
+        {"2026-01-31T23:00", time.Date(2026, 1, 31, 23, 0, 0, 0, time.Local), true},
+        {"2026-01-31T23:00:00Z", time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC), true},
+        {"yesterday", time.Time{}, false},
+        {"7days", time.Time{}, false},
+        {"d", time.Time{}, false},
+        {"2026-13-01", time.Time{}, false},
+    }
+    for _, tt := range tests {
+        got, err := parseWhen(tt.in, now)
+        if (err == nil) != tt.ok {
+            t.Errorf("parseWhen(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+            continue
+        }
+        if !got.Equal(tt.want) {
+            t.Errorf("parseWhen(%q) = %v, want %v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestExportFormat(t *testing.T) {
+    tests := []struct {
+        format, path string
+        want         string // "" for an error
+    }{
+        {"", "-", "csv"},
+        {"", "out.csv", "csv"},
+        {"", "out.json", "json"},
+        {"", "OUT.JSON", "json"},
+        {"", "report.txt", "csv"},
+        {"", "out.log", "csv"},
+        {"", "noext", "csv"},
+        {"json", "out.csv", "json"},
+        {"CSV", "out.json", "csv"},
+        {"xml", "out.xml", ""},
+    }
+    for _, tt := range tests {
+        got, err := exportFormat(tt.format, tt.path)
+        if tt.want == "" {
+            if err == nil {
+                t.Errorf("exportFormat(%q, %q) = %q, want an error", tt.format, tt.path, got)
+            }
+            continue
+        }
+        if err != nil || got != tt.want {
+            t.Errorf("exportFormat(%q, %q) = %q, %v; want %q", tt.format, tt.path, got, err, tt.want)
+        }
+    }
+}
+
+func TestLatestRows(t *testing.T) {
+    row := func(host string, s int, status string) exportRow {
+        return exportRow{Record: "round", Time: at("20260101", int64(s)*1000), Host: host, Status: status}
+    }
+    rows := []exportRow{row("b", 1, "UP"), row("a", 2, "UP"), row("b", 3, "DOWN"), row("a", 4, "DEGRADED")}
+    got := latestRows(rows)
+    want := []exportRow{row("a", 4, "DEGRADED"), row("b", 3, "DOWN")}
+    for i := range want {
+        want[i].Record = "status"
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("latestRows = %+v, want %+v", got, want)
+    }
+    if rows[3].Record != "round" {
+        t.Error("latestRows changed its input")
+    }
+    if got := latestRows(nil); len(got) != 0 {
+        t.Errorf("latestRows(nil) = %+v, want none", got)
+    }
+}
+
+// writeExportData writes a compacted day and a raw day of records to dir.
+func writeExportData(t *testing.T, dir string) {
+    t.Helper()
+    writeSegment(t, dir, "20260101.cseg",
+        record{kind: recAggregate, at: at("20260101", 60_000), key: "db01", rounds: 12, up: 10,
+            sent: 12, recv: 10, timed: 10, rttSum: 50}.encode(),
+        record{kind: recAggregate, at: at("20260101", 120_000), key: "web", rounds: 12, up: 2,
+            sent: 12, recv: 2, timed: 0}.encode(),
+    )
+    writeSegment(t, dir, "20260102.seg",
+        record{kind: recRound, at: at("20260102", 1000), key: "db01", state: stateUp, sent: 2, recv: 2, rtt: 4}.encode(),
+        record{kind: recTransition, at: at("20260102", 2000), key: "db01", from: stateUp, state: stateDown, reason: "timeout"}.encode(),
+        record{kind: recRound, at: at("20260102", 2000), key: "db01", state: stateDown, sent: 2, recv: 0, rtt: -1, reason: "timeout"}.encode(),
+        record{kind: recRound, at: at("20260102", 3000), key: "tcp://WEB:80", state: stateUp, sent: 1, recv: 1, rtt: 7}.encode(),
+    )
+}
+
+func TestExportHistory(t *testing.T) {
+    dir := t.TempDir()
+    writeExportData(t, dir)
+    scan := func(from, to time.Time, fn func(record) bool) error { return scanDir(dir, from, to, fn) }
+    now := at("20260103", 0)
+    f64 := func(v float64) *float64 { return &v }
+    tests := []struct {
+        name string
+        f    exportFilter
+        want []exportRow
+    }{
+        {"all", exportFilter{}, []exportRow{
+            {Record: "minute", Time: at("20260101", 60_000), Host: "db01", Status: "UP", RTT: f64(5), Loss: f64(100.0 * 2 / 12), Sent: 12, Recv: 10, Rounds: 12},
+            {Record: "minute", Time: at("20260101", 120_000), Host: "web", Status: "DOWN", Loss: f64(100.0 * 10 / 12), Sent: 12, Recv: 2, Rounds: 12},
+            {Record: "round", Time: at("20260102", 1000), Host: "db01", Status: "UP", RTT: f64(4), Loss: f64(0), Sent: 2, Recv: 2, Rounds: 1},
+            {Record: "round", Time: at("20260102", 2000), Host: "db01", Status: "DOWN", Loss: f64(100), Sent: 2, Rounds: 1, Reason: "timeout"},
+            {Record: "round", Time: at("20260102", 3000), Host: "tcp://WEB:80", Status: "UP", RTT: f64(7), Loss: f64(0), Sent: 1, Recv: 1, Rounds: 1},
+        }},
+        {"host filter ignores case", exportFilter{host: "web"}, []exportRow{
+            {Record: "minute", Time: at("20260101", 120_000), Host: "web", Status: "DOWN", Loss: f64(100.0 * 10 / 12), Sent: 12, Recv: 2, Rounds: 12},
+            {Record: "round", Time: at("20260102", 3000), Host: "tcp://WEB:80", Status: "UP", RTT: f64(7), Loss: f64(0), Sent: 1, Recv: 1, Rounds: 1},
+        }},
+        {"from is inclusive, to exclusive", exportFilter{host: "db01", from: at("20260102", 1000), to: at("20260102", 2000)}, []exportRow{
+            {Record: "round", Time: at("20260102", 1000), Host: "db01", Status: "UP", RTT: f64(4), Loss: f64(0), Sent: 2, Recv: 2, Rounds: 1},
+        }},
+        {"to after now", exportFilter{from: at("20260102", 2500), to: at("20260105", 0)}, []exportRow{
+            {Record: "round", Time: at("20260102", 3000), Host: "tcp://WEB:80", Status: "UP", RTT: f64(7), Loss: f64(0), Sent: 1, Recv: 1, Rounds: 1},
+        }},
+        {"nothing matches", exportFilter{host: "mail"}, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := exportHistory(scan, tt.f, now)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("exportHistory =\n  %s\nwant\n  %s", rowsText(got), rowsText(tt.want))
+            }
+        })
+    }
+}
+
+// rowsText formats rows readably, with the values of their pointer fields.
+func rowsText(rows []exportRow) string {
+    var b bytes.Buffer
+    json.NewEncoder(&b).Encode(rows)
+    return b.String()
+}
+
+func TestWriteExport(t *testing.T) {
+    when := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+    rtt, loss := 12.5, 50.0
+    status := []exportRow{{Record: "status", Time: when, Host: "db01", Status: "DEGRADED", RTT: &rtt, Loss: &loss,
+        Sent: 2, Recv: 1, Rounds: 1, Reason: "packet loss, with a comma"}}
+    history := []exportRow{{Record: "round", Time: when.Add(-time.Second), Host: "db01", Status: "DOWN", Sent: 0, Rounds: 1}}
+
+    var b bytes.Buffer
+    if err := writeExport(&b, "csv", when, status, history); err != nil {
+        t.Fatal(err)
+    }
+    lines, err := csv.NewReader(&b).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := [][]string{
+        exportColumns,
+        {"status", "2026-01-02T03:04:05.000Z", "db01", "DEGRADED", "12.500", "50.000", "2", "1", "1", "packet loss, with a comma"},
+        {"round", "2026-01-02T03:04:04.000Z", "db01", "DOWN", "", "", "0", "0", "1", ""},
+    }
+    if !reflect.DeepEqual(lines, want) {
+        t.Errorf("CSV =\n  %q\nwant\n  %q", lines, want)
+    }
+
+    b.Reset()
+    if err := writeExport(&b, "json", when, status, history); err != nil {
+        t.Fatal(err)
+    }
+    var doc map[string]any
+    if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+        t.Fatal(err)
+    }
+    if doc["exported_at"] != "2026-01-02T03:04:05Z" {
+        t.Errorf("exported_at = %v", doc["exported_at"])
+    }
+    st, _ := doc["status"].([]any)
+    hist, _ := doc["history"].([]any)
+    if len(st) != 1 || len(hist) != 1 {
+        t.Fatalf("%d status and %d history rows, want 1 each: %s", len(st), len(hist), b.String())
+    }
+    row := st[0].(map[string]any)
+    for k, v := range map[string]any{"record": "status", "host": "db01", "status": "DEGRADED", "rtt_ms": 12.5,
+        "loss_pct": 50.0, "sent": 2.0, "recv": 1.0, "rounds": 1.0, "reason": "packet loss, with a comma"} {
+        if row[k] != v {
+            t.Errorf("status row %s = %v, want %v", k, row[k], v)
+        }
+    }
+    row = hist[0].(map[string]any)
+    if v, ok := row["rtt_ms"]; !ok || v != nil {
+        t.Errorf("history row rtt_ms = %v, want null", v)
+    }
+    if _, ok := row["reason"]; ok {
+        t.Error("history row has an empty reason")
+    }
+}
+
+func TestExportDialog(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "out.report")
+    m := newTestModel(t, "db01", "web")
+    m, _ = send(m, upResult(m.schedGen, idOf(m, "db01"), 1, 10))
+    m, _ = send(m, keys("x", "db", "tab", "tab", "tab")...)
+    m.exportFile.SetValue(path)
+    m, cmd := send(m, keyMsg("enter"))
+    if m.mode != modeList || cmd == nil || m.exporting != path {
+        t.Fatalf("mode %v, exporting %q, command %v; want the list, %q and a command", m.mode, m.exporting, cmd != nil, path)
+    }
+    if _, err := os.Stat(path); err == nil {
+        t.Error("the file was written before the command ran")
+    }
+    m, _ = send(m, cmd())
+    if m.exporting != "" || !strings.HasPrefix(m.message, "Exported 1 hosts and 0 rounds") {
+        t.Errorf("after the export: exporting %q, message %q", m.exporting, m.message)
+    }
+    out, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "status,") {
+        t.Errorf("exported\n%s\nwant the CSV header and db01's status", out)
+    }
+}
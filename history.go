@@ -0,0 +1,359 @@
+package main
+
+import (
+    "math"
+    "slices"
+    "strings"
+    "time"
+)
+
+// historySpan is the stretch of rounds a history keeps at full resolution,
+// for the 1 h window of the detail chart and stats windows up to an hour.
+// Older rounds only survive in per‑minute aggregates, of which
+// historyMinutes are kept: a day's worth, for the 24 h chart and longer
+// stats windows.
+const (
+    historySpan    = time.Hour
+    historyMinutes = 24 * 60
+)
+
+// historySizeFor returns how many rounds the history of h keeps at full
+// resolution: those within historySpan at its interval. A tenth more leaves
+// room for rounds that came early after a reschedule, and the trend column
+// is always filled.
+func (m model) historySizeFor(h Host) int {
+    iv := m.intervalFor(h)
+    if iv <= 0 {
+        iv = time.Second
+    }
+    n := int(historySpan/iv) + 1
+    return max(n+n/10, maxTrendWidth)
+}
+
+// historyFor returns the history of host h, creating it on first use, with
+// its ring sized for the host's current interval and stats window.
+func (m *model) historyFor(h Host) *history {
+    if m.history == nil {
+        m.history = make(map[int]*history)
+    }
+    hist := m.history[h.ID]
+    if hist == nil {
+        hist = &history{}
+        m.history[h.ID] = hist
+    }
+    hist.resize(m.historySizeFor(h))
+    return hist
+}
+
+// sample is the outcome of one round as kept in a host's history. It is
+// kept compact since there are many of them.
+type sample struct {
+    at   int64   // Unix time in milliseconds
+    rtt  float32 // average reply time in milliseconds, -1 if nothing answered
+    sent uint8
+    recv uint8
+}
+
+// sampleOf turns the result of a finished round into a history sample.
+func sampleOf(res pingResult, at time.Time) sample {
+    s := sample{at: at.UnixMilli(), rtt: -1, sent: uint8(res.stats.sent), recv: uint8(res.stats.recv)}
+    if res.stats.timed > 0 {
+        s.rtt = float32(res.stats.avg)
+    }
+    return s
+}
+
+// time returns when the sample was taken.
+func (s sample) time() time.Time {
+    return time.UnixMilli(s.at)
+}
+
+// lost reports whether no probe of the round was answered.
+func (s sample) lost() bool {
+    return s.recv == 0
+}
+
+// minuteAgg sums up the rounds of one minute, much like the records of a
+// compacted store segment.
+type minuteAgg struct {
+    minute     uint32 // Unix time in minutes
+    rounds     uint16
+    sent, recv uint16
+    timed      uint16  // rounds with a reply time
+    sum        float32 // of reply times
+    min, max   float32 // reply times
+    sumSq      float64 // of reply times, for the standard deviation
+}
+
+// time returns the start of the minute.
+func (a minuteAgg) time() time.Time {
+    return time.Unix(int64(a.minute)*60, 0)
+}
+
+// history keeps a host's most recent samples in a ring buffer, and every
+// sample folded into per‑minute aggregates in a second, longer ring.
+type history struct {
+    samples []sample
+    start   int   // index of the oldest sample once the buffer is full
+    size    int   // capacity of the ring, see resize; 0 means maxTrendWidth
+    dropped int64 // time of the newest sample dropped from the ring, 0 for none
+
+    minutes []minuteAgg
+    mstart  int // index of the oldest aggregate once the buffer is full
+
+    // lat caches the latency statistics over latWindow until the next
+    // sample arrives; latWindow is 0 while there are none.
+    lat       latencyStats
+    latWindow time.Duration
+}
+
+// grown returns s with room for more elements, up to limit in total.
+// Buffers grow as samples arrive, so hosts only use the full size after
+// running long enough.
+func grown[T any](s []T, limit int) []T {
+    g := make([]T, len(s), min(max(2*cap(s), 64), limit))
+    copy(g, s)
+    return g
+}
+
+// add records a sample, dropping the oldest one when the buffer is full,
+// and folds it into the aggregate of its minute.
+func (h *history) add(s sample) {
+    h.latWindow = 0
+    h.addMinute(s)
+    if h.size == 0 {
+        h.size = maxTrendWidth
+    }
+    if len(h.samples) < h.size {
+        if len(h.samples) == cap(h.samples) {
+            h.samples = grown(h.samples, h.size)
+        }
+        h.samples = append(h.samples, s)
+        return
+    }
+    h.dropped = max(h.dropped, h.samples[h.start].at)
+    h.samples[h.start] = s
+    h.start = (h.start + 1) % h.size
+}
+
+// addMinute folds s into the aggregate of its minute. Samples are expected
+// in order; a late one counts towards the newest minute.
+func (h *history) addMinute(s sample) {
+    minute := uint32(s.at / 60000)
+    n := len(h.minutes)
+    if n == 0 || h.minuteAt(n-1).minute < minute {
+        a := minuteAgg{minute: minute}
+        if n < historyMinutes {
+            if n == cap(h.minutes) {
+                h.minutes = grown(h.minutes, historyMinutes)
+            }
+            h.minutes = append(h.minutes, a)
+        } else {
+            h.minutes[h.mstart] = a
+            h.mstart = (h.mstart + 1) % historyMinutes
+        }
+        n = len(h.minutes)
+    }
+    a := &h.minutes[(h.mstart+n-1)%n]
+    a.rounds++
+    a.sent += uint16(s.sent)
+    a.recv += uint16(s.recv)
+    if s.rtt >= 0 && !s.lost() {
+        if a.timed == 0 || s.rtt < a.min {
+            a.min = s.rtt
+        }
+        a.max = max(a.max, s.rtt)
+        a.timed++
+        a.sum += s.rtt
+        a.sumSq += float64(s.rtt) * float64(s.rtt)
+    }
+}
+
+// resize changes the capacity of the ring to n, dropping the oldest samples
+// if more are held.
+func (h *history) resize(n int) {
+    if n == h.size {
+        return
+    }
+    h.latWindow = 0
+    keep := h.last(n)
+    if drop := h.len() - len(keep); drop > 0 {
+        h.dropped = max(h.dropped, h.at(drop-1).at)
+    }
+    h.samples = grown(keep, n)
+    h.start = 0
+    h.size = n
+}
+
+// clone returns a copy of h that shares nothing with it.
+func (h *history) clone() *history {
+    c := *h
+    c.samples = slices.Clone(h.samples)
+    c.minutes = slices.Clone(h.minutes)
+    return &c
+}
+
+// len returns the number of samples held.
+func (h *history) len() int {
+    return len(h.samples)
+}
+
+// at returns the i‑th sample, counting from the oldest.
+func (h *history) at(i int) sample {
+    return h.samples[(h.start+i)%len(h.samples)]
+}
+
+// last returns up to n of the most recent samples, oldest first.
+func (h *history) last(n int) []sample {
+    if n > h.len() {
+        n = h.len()
+    }
+    out := make([]sample, n)
+    for i := range out {
+        out[i] = h.at(h.len() - n + i)
+    }
+    return out
+}
+
+// since returns the samples taken at or after t, oldest first.
+func (h *history) since(t time.Time) []sample {
+    ms := t.UnixMilli()
+    n := 0
+    for n < h.len() && h.at(h.len()-1-n).at >= ms {
+        n++
+    }
+    return h.last(n)
+}
+
+// latency returns the latency statistics of the samples within window
+// before the newest one. They are computed when first asked for, typically
+// to draw the host's row, and kept until the next sample arrives. A window
+// reaching back past the ring takes the older rounds from the minute
+// aggregates, see latencyOfMinutes. A nil history has no statistics.
+func (h *history) latency(window time.Duration) latencyStats {
+    if h == nil || h.len() == 0 {
+        return latencyStats{}
+    }
+    if h.latWindow != window {
+        from := h.at(h.len() - 1).time().Add(-window)
+        if h.covers(from) {
+            h.lat = latencyOf(h.since(from))
+        } else {
+            h.lat = h.latencyOfMinutes(from)
+        }
+        h.latWindow = window
+    }
+    return h.lat
+}
+
+// latencyOfMinutes computes the latency statistics since from for a window
+// longer than the ring: the minutes before the ring's first full minute
+// count by their aggregates, the rest by their samples. The minute from
+// falls in counts in full. The standard
+// deviation is exact; the percentiles take a minute's rounds as all having
+// its average reply time. The jitter only depends on the most recent reply
+// times and is taken from the samples.
+func (h *history) latencyOfMinutes(from time.Time) latencyStats {
+    cut := uint32(h.dropped/60000) + 1 // first minute the ring holds in full
+    first := uint32(from.Unix() / 60)
+    var vals []weighted
+    var n int
+    var sum, sumSq float64
+    for i := 0; i < h.minuteLen(); i++ {
+        a := h.minuteAt(i)
+        if a.minute < first || a.minute >= cut || a.timed == 0 {
+            continue
+        }
+        vals = append(vals, weighted{float64(a.sum) / float64(a.timed), int(a.timed)})
+        n += int(a.timed)
+        sum += float64(a.sum)
+        sumSq += a.sumSq
+    }
+    var recent []sample
+    for _, s := range h.since(time.UnixMilli(int64(cut) * 60000)) {
+        if s.rtt >= 0 && !s.lost() {
+            recent = append(recent, s)
+            v := float64(s.rtt)
+            vals = append(vals, weighted{v, 1})
+            n++
+            sum += v
+            sumSq += v * v
+        }
+    }
+    st := latencyOf(recent)
+    st.n = n
+    if n == 0 {
+        return st
+    }
+    avg := sum / float64(n)
+    st.stddev = math.Sqrt(math.Max(sumSq/float64(n)-avg*avg, 0))
+    st.p50 = weightedPercentile(vals, 50)
+    st.p90 = weightedPercentile(vals, 90)
+    st.p95 = weightedPercentile(vals, 95)
+    st.p99 = weightedPercentile(vals, 99)
+    return st
+}
+
+// covers reports whether the ring still holds every sample taken at or
+// after t.
+func (h *history) covers(t time.Time) bool {
+    return h.dropped == 0 || h.dropped < t.UnixMilli()
+}
+
+// minuteLen returns the number of minute aggregates held.
+func (h *history) minuteLen() int {
+    return len(h.minutes)
+}
+
+// minuteAt returns the i‑th minute aggregate, counting from the oldest.
+func (h *history) minuteAt(i int) minuteAgg {
+    return h.minutes[(h.mstart+i)%len(h.minutes)]
+}
+
+// sparkBlocks are the bar glyphs of the sparkline, lowest first; sparkLost
+// marks a round in which nothing answered.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+const sparkLost = '×'
+
+// sparkline renders the most recent samples of h as exactly width runes,
+// right aligned. Bars are scaled between the lowest and highest reply time
+// shown.
+func sparkline(h *history, width int) string {
+    if width <= 0 {
+        return ""
+    }
+    var samples []sample
+    if h != nil {
+        samples = h.last(width)
+    }
+    lo, hi := float32(-1), float32(-1)
+    for _, s := range samples {
+        if s.rtt < 0 {
+            continue
+        }
+        if lo < 0 || s.rtt < lo {
+            lo = s.rtt
+        }
+        if s.rtt > hi {
+            hi = s.rtt
+        }
+    }
+    var b strings.Builder
+    b.WriteString(strings.Repeat(" ", width-len(samples)))
+    for _, s := range samples {
+        switch {
+        case s.lost():
+            b.WriteRune(sparkLost)
+        case s.rtt < 0:
+            // Answered, but without a usable reply time
+            b.WriteRune(sparkBlocks[0])
+        case hi == lo:
+            b.WriteRune(sparkBlocks[len(sparkBlocks)/2])
+        default:
+            i := int((s.rtt - lo) / (hi - lo) * float32(len(sparkBlocks)-1))
+            b.WriteRune(sparkBlocks[i])
+        }
+    }
+    return b.String()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+    "math"
+    "testing"
+    "time"
+)
+
+// minuteSample returns a sample taken sec seconds into the given minute of
+// day zero.
+func minuteSample(minute, sec int, rtt float32) sample {
+    s := sample{at: int64(minute*60+sec) * 1000, rtt: rtt, sent: 1, recv: 1}
+    if rtt < 0 {
+        s.recv = 0
+    }
+    return s
+}
+
+func TestHistoryRing(t *testing.T) {
+    h := &history{}
+    h.resize(100)
+    for i := 0; i < 250; i++ {
+        h.add(sample{at: int64(i) * 1000, rtt: float32(i), sent: 1, recv: 1})
+    }
+    if h.len() != 100 || h.at(0).at != 150_000 || h.at(99).at != 249_000 {
+        t.Fatalf("ring holds %d samples from %d to %d, want 100 from 150000 to 249000",
+            h.len(), h.at(0).at, h.at(h.len()-1).at)
+    }
+    if cap(h.samples) > 100 {
+        t.Errorf("ring capacity %d exceeds its size", cap(h.samples))
+    }
+    if !h.covers(time.UnixMilli(150_000)) || h.covers(time.UnixMilli(149_000)) {
+        t.Errorf("covers: dropped %d, want the ring to cover from 150000 on", h.dropped)
+    }
+    // Shrinking keeps the newest samples, growing keeps them all.
+    h.resize(10)
+    if h.len() != 10 || h.at(0).at != 240_000 || h.covers(time.UnixMilli(239_000)) {
+        t.Fatalf("after shrinking: %d samples from %d", h.len(), h.at(0).at)
+    }
+    h.resize(20)
+    for i := 250; i < 260; i++ {
+        h.add(sample{at: int64(i) * 1000, sent: 1, recv: 1})
+    }
+    if h.len() != 20 || h.at(0).at != 240_000 || h.at(19).at != 259_000 {
+        t.Fatalf("after growing: %d samples from %d to %d", h.len(), h.at(0).at, h.at(h.len()-1).at)
+    }
+}
+
+func TestHistoryMinutes(t *testing.T) {
+    h := &history{}
+    h.add(minuteSample(0, 5, 10))
+    h.add(minuteSample(0, 35, 30))
+    h.add(minuteSample(0, 50, -1))
+    h.add(minuteSample(2, 0, 7))
+    if h.minuteLen() != 2 {
+        t.Fatalf("%d minutes, want 2", h.minuteLen())
+    }
+    want := minuteAgg{minute: 0, rounds: 3, sent: 3, recv: 2, timed: 2, sum: 40, min: 10, max: 30, sumSq: 1000}
+    if got := h.minuteAt(0); got != want {
+        t.Errorf("minute 0 = %+v, want %+v", got, want)
+    }
+    // The minute ring keeps a day and drops the oldest minutes after that.
+    for m := 3; m < historyMinutes+10; m++ {
+        h.add(minuteSample(m, 0, 1))
+    }
+    if h.minuteLen() != historyMinutes || h.minuteAt(0).minute != 10 {
+        t.Errorf("%d minutes from %d, want %d from 10", h.minuteLen(), h.minuteAt(0).minute, historyMinutes)
+    }
+}
+
+func TestHistorySizeFor(t *testing.T) {
+    m := model{interval: 5 * time.Second, statWindow: 5 * time.Minute}
+    if n := m.historySizeFor(Host{}); n < 720 || n > 800 {
+        t.Errorf("an hour at 5 s: %d samples, want about 720", n)
+    }
+    // Longer stats windows use the minute aggregates instead
+    if n := m.historySizeFor(Host{Window: 24 * time.Hour}); n < 720 || n > 800 {
+        t.Errorf("a 24 h window at 5 s: %d samples, want about 720", n)
+    }
+    if n := m.historySizeFor(Host{Interval: 500 * time.Millisecond, Window: 24 * time.Hour}); n < 7200 || n > 8000 {
+        t.Errorf("a 24 h window at 0.5 s: %d samples, want about 7200", n)
+    }
+    if n := m.historySizeFor(Host{Interval: 5 * time.Minute}); n != maxTrendWidth {
+        t.Errorf("an hour at 5 min: %d samples, want the trend width %d", n, maxTrendWidth)
+    }
+}
+
+func TestChartDataFromMinutes(t *testing.T) {
+    h := &history{}
+    h.resize(maxTrendWidth)
+    // Two hours of rounds every 10 s: the ring keeps the last ten minutes.
+    for sec := 0; sec < 7200; sec += 10 {
+        rtt := float32(20)
+        if sec >= 3600 {
+            rtt = 40
+        }
+        h.add(minuteSample(0, sec, rtt))
+    }
+    now := time.UnixMilli(7200 * 1000)
+    buckets, st := chartData(h, 5*time.Minute, now, 5)
+    if st.rounds != 30 || st.avg != 40 || buckets[0].rounds != 6 {
+        t.Errorf("5 min window: %d rounds, avg %v, first bucket %d rounds; want 30, 40 and 6",
+            st.rounds, st.avg, buckets[0].rounds)
+    }
+    buckets, st = chartData(h, 2*time.Hour, now, 4)
+    if st.rounds != 720 || st.min != 20 || st.max != 40 || st.avg != 30 {
+        t.Errorf("2 h window: %d rounds, min/avg/max %v/%v/%v; want 720, 20/30/40",
+            st.rounds, st.min, st.avg, st.max)
+    }
+    for k, b := range buckets {
+        if b.rounds != 180 {
+            t.Errorf("bucket %d: %d rounds, want 180", k, b.rounds)
+        }
+    }
+}
+
+func TestHistoryLatency(t *testing.T) {
+    h := &history{}
+    for sec, rtt := range []float32{10, 20, 10, 20, -1, 10} {
+        h.add(minuteSample(0, sec, rtt))
+    }
+    st := h.latency(time.Minute)
+    if st.n != 5 || st.p50 != 10 || st.p99 < 19 {
+        t.Errorf("latency = %+v, want 5 rounds with p50 10 ms", st)
+    }
+    // RFC 3550: J += (|D| - J) / 16 for each of the four differences of 10 ms
+    j := 0.0
+    for k := 0; k < 4; k++ {
+        j += (10 - j) / 16
+    }
+    if math.Abs(st.jitter-j) > 1e-9 {
+        t.Errorf("jitter = %v, want %v", st.jitter, j)
+    }
+    // The window ends at the newest sample; a new sample invalidates the
+    // cached statistics.
+    if st := h.latency(2 * time.Second); st.n != 2 || st.p50 != 15 {
+        t.Errorf("2 s window: %+v, want 2 rounds with p50 15 ms", st)
+    }
+    h.add(minuteSample(0, 6, 30))
+    if st := h.latency(2 * time.Second); st.n != 2 || st.p50 != 20 {
+        t.Errorf("2 s window after a new round: %+v, want 2 rounds with p50 20 ms", st)
+    }
+}
+
+func TestHistoryLatencyFromMinutes(t *testing.T) {
+    h := &history{}
+    h.resize(maxTrendWidth)
+    // Two hours of rounds every 10 s: 20 ms in the first hour, 40 ms in the
+    // second, with the ring holding the last ten minutes.
+    for sec := 0; sec < 7200; sec += 10 {
+        rtt := float32(20)
+        if sec >= 3600 {
+            rtt = 40
+        }
+        h.add(minuteSample(0, sec, rtt))
+    }
+    st := h.latency(2 * time.Hour)
+    if st.n != 720 || st.p50 != 20 || st.p90 != 40 || math.Abs(st.stddev-10) > 1e-6 {
+        t.Errorf("2 h window: %+v, want 720 rounds, p50 20 ms, p90 40 ms and stddev 10 ms", st)
+    }
+    if st.jitter != 0 {
+        t.Errorf("2 h window: jitter %v of the recent steady rounds, want 0", st.jitter)
+    }
+    // 30 minutes: all in the second hour, partly from the aggregates, of
+    // which the minute the window starts in counts in full
+    if st := h.latency(30 * time.Minute); st.n != 186 || st.p50 != 40 || st.stddev != 0 {
+        t.Errorf("30 min window: %+v, want 186 rounds of 40 ms", st)
+    }
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// addrFamily selects which IP address family a host is probed over.
+type addrFamily int
+
+const (
+    familyAuto addrFamily = iota // whatever the resolver returns first
+    family4                      // IPv4 only
+    family6                      // IPv6 only
+    familyBoth                   // IPv4 and IPv6, reported separately
+)
+
+// String returns the spelling used for the family option in hosts.txt.
+func (f addrFamily) String() string {
+    switch f {
+    case family4:
+        return "4"
+    case family6:
+        return "6"
+    case familyBoth:
+        return "both"
+    }
+    return "auto"
+}
+
+// parseFamily parses the value of the family option.
+func parseFamily(s string) (addrFamily, error) {
+    switch strings.ToLower(s) {
+    case "", "auto":
+        return familyAuto, nil
+    case "4", "v4", "ipv4":
+        return family4, nil
+    case "6", "v6", "ipv6":
+        return family6, nil
+    case "both", "dual", "46":
+        return familyBoth, nil
+    }
+    return familyAuto, fmt.Errorf("unknown address family %q", s)
+}
+
+// parseHostSpec parses the host column of a hosts.txt line. The column holds
+// the target optionally followed by whitespace separated key=value options,
+// for example "gnu.org family=both" or "tcp://db01:5432 family=6". Option
+// values are URL‑unescaped, so "match=Hello%20World" matches a space.
+func parseHostSpec(spec string) (Host, error) {
+    fields := strings.Fields(spec)
+    if len(fields) == 0 {
+        return Host{}, fmt.Errorf("empty host")
+    }
+    kind, err := parseTarget(fields[0])
+    if err != nil {
+        return Host{}, err
+    }
+    h := Host{Host: fields[0], Probe: kind}
+    for _, opt := range fields[1:] {
+        key, val, ok := strings.Cut(opt, "=")
+        if !ok {
+            return Host{}, fmt.Errorf("option %q is not of the form key=value", opt)
+        }
+        val, err := url.QueryUnescape(val)
+        if err != nil {
+            return Host{}, fmt.Errorf("option %q: %v", key, err)
+        }
+        switch strings.ToLower(key) {
+        case "family", "af":
+            f, err := parseFamily(val)
+            if err != nil {
+                return Host{}, err
+            }
+            h.Family = f
+        case "status":
+            r, err := parseStatusRange(val)
+            if err != nil {
+                return Host{}, err
+            }
+            h.Expect = r
+        case "match":
+            h.Match = val
+        case "regex":
+            re, err := regexp.Compile(val)
+            if err != nil {
+                return Host{}, fmt.Errorf("invalid regex: %v", err)
+            }
+            h.Regex = re
+        case "redirects":
+            n, err := parseRedirects(val)
+            if err != nil {
+                return Host{}, err
+            }
+            h.Redirects = n
+        case "sni":
+            h.SNI = val
+        case "certwarn":
+            n, err := strconv.Atoi(val)
+            if err != nil || n <= 0 {
+                return Host{}, fmt.Errorf("invalid certwarn %q, want a number of days", val)
+            }
+            h.CertWarn = n
+        case "count":
+            n, err := strconv.Atoi(val)
+            if err != nil || n < 1 || n > maxCount {
+                return Host{}, fmt.Errorf("invalid count %q, want 1–%d", val, maxCount)
+            }
+            h.Count = n
+        case "interval":
+            d, err := parseSeconds(val)
+            if err != nil || d < 500*time.Millisecond {
+                return Host{}, fmt.Errorf("invalid interval %q, want at least 0.5s", val)
+            }
+            h.Interval = d
+        case "jitter":
+            d, err := parseSeconds(val)
+            if err != nil || d < 0 {
+                return Host{}, fmt.Errorf("invalid jitter %q", val)
+            }
+            h.Jitter = d
+        case "fall":
+            n, err := strconv.Atoi(val)
+            if err != nil || n < 1 || n > maxThreshold {
+                return Host{}, fmt.Errorf("invalid fall %q, want 1–%d rounds", val, maxThreshold)
+            }
+            h.Fall = n
+        case "rise":
+            n, err := strconv.Atoi(val)
+            if err != nil || n < 1 || n > maxThreshold {
+                return Host{}, fmt.Errorf("invalid rise %q, want 1–%d rounds", val, maxThreshold)
+            }
+            h.Rise = n
+        case "warn":
+            d, err := parseMillis(val)
+            if err != nil || d <= 0 {
+                return Host{}, fmt.Errorf("invalid warn %q, want a latency such as 150 or 150ms", val)
+            }
+            h.Warn = d
+        case "crit":
+            d, err := parseMillis(val)
+            if err != nil || d <= 0 {
+                return Host{}, fmt.Errorf("invalid crit %q, want a latency such as 500 or 500ms", val)
+            }
+            h.Crit = d
+        case "window":
+            d, err := parseSeconds(val)
+            if err != nil || d < minStatWindow || d > maxStatWindow {
+                return Host{}, fmt.Errorf("invalid window %q, want %v–%v", val, minStatWindow, maxStatWindow)
+            }
+            h.Window = d
+        default:
+            return Host{}, fmt.Errorf("unknown option %q", key)
+        }
+    }
+    if h.Warn > 0 && h.Crit > 0 && h.Warn >= h.Crit {
+        return Host{}, fmt.Errorf("warn %v must be below crit %v", h.Warn, h.Crit)
+    }
+    return h, nil
+}
+
+// parseMillis parses a latency such as "150ms" or "1s". A bare number is
+// taken as milliseconds.
+func parseMillis(s string) (time.Duration, error) {
+    s = strings.ReplaceAll(s, ",", ".")
+    if _, err := strconv.ParseFloat(s, 64); err == nil {
+        s += "ms"
+    }
+    return time.ParseDuration(s)
+}
+
+// parseSeconds parses a duration such as "10s" or "1m". A bare number is
+// taken as seconds, with a decimal comma accepted like in the options dialog.
+func parseSeconds(s string) (time.Duration, error) {
+    s = strings.ReplaceAll(s, ",", ".")
+    if _, err := strconv.ParseFloat(s, 64); err == nil {
+        s += "s"
+    }
+    return time.ParseDuration(s)
+}
+
+// spec formats the host column for hosts.txt, the inverse of parseHostSpec.
+// Options left at their defaults are omitted.
+func (h Host) spec() string {
+    parts := []string{h.Host}
+    if h.Family != familyAuto {
+        parts = append(parts, "family="+h.Family.String())
+    }
+    if h.Expect.lo != 0 {
+        parts = append(parts, "status="+h.Expect.String())
+    }
+    if h.Match != "" {
+        parts = append(parts, "match="+url.QueryEscape(h.Match))
+    }
+    if h.Regex != nil {
+        parts = append(parts, "regex="+url.QueryEscape(h.Regex.String()))
+    }
+    switch {
+    case h.Redirects < 0:
+        parts = append(parts, "redirects=none")
+    case h.Redirects > 0:
+        parts = append(parts, "redirects="+strconv.Itoa(h.Redirects))
+    }
+    if h.SNI != "" {
+        parts = append(parts, "sni="+url.QueryEscape(h.SNI))
+    }
+    if h.CertWarn != 0 {
+        parts = append(parts, "certwarn="+strconv.Itoa(h.CertWarn))
+    }
+    if h.Count != 0 {
+        parts = append(parts, "count="+strconv.Itoa(h.Count))
+    }
+    if h.Interval != 0 {
+        parts = append(parts, "interval="+h.Interval.String())
+    }
+    if h.Jitter != 0 {
+        parts = append(parts, "jitter="+h.Jitter.String())
+    }
+    if h.Fall != 0 {
+        parts = append(parts, "fall="+strconv.Itoa(h.Fall))
+    }
+    if h.Rise != 0 {
+        parts = append(parts, "rise="+strconv.Itoa(h.Rise))
+    }
+    if h.Warn != 0 {
+        parts = append(parts, "warn="+h.Warn.String())
+    }
+    if h.Crit != 0 {
+        parts = append(parts, "crit="+h.Crit.String())
+    }
+    if h.Window != 0 {
+        parts = append(parts, "window="+h.Window.String())
+    }
+    return strings.Join(parts, " ")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+    "context"
+    "crypto/tls"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptrace"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// httpTimeout bounds a whole HTTP check including redirects and reading the
+// body. Web requests legitimately take longer than an echo or a connect.
+const httpTimeout = 5 * time.Second
+
+// httpMaxBody caps how much of the response body is read for matching.
+const httpMaxBody = 1 << 20
+
+// httpDefaultRedirects is how many redirects are followed unless the host
+// sets the redirects option.
+const httpDefaultRedirects = 10
+
+// errTooManyRedirects fails a check whose redirects exceed the host's limit.
+var errTooManyRedirects = errors.New("too many redirects")
+
+// statusRange is an inclusive range of acceptable HTTP status codes. The zero
+// value accepts 200–399.
+type statusRange struct {
+    lo, hi int
+}
+
+// contains reports whether code lies within the range.
+func (r statusRange) contains(code int) bool {
+    if r.lo == 0 {
+        return code >= 200 && code <= 399
+    }
+    return code >= r.lo && code <= r.hi
+}
+
+// String formats the range as used by the status option.
+func (r statusRange) String() string {
+    if r.lo == r.hi {
+        return strconv.Itoa(r.lo)
+    }
+    return fmt.Sprintf("%d-%d", r.lo, r.hi)
+}
+
+// parseStatusRange parses "200" or "200-299". A shorthand like "2xx" is also
+// accepted.
+func parseStatusRange(s string) (statusRange, error) {
+    s = strings.ToLower(s)
+    if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+        lo := int(s[0]-'0') * 100
+        return statusRange{lo, lo + 99}, nil
+    }
+    loStr, hiStr, isRange := strings.Cut(s, "-")
+    lo, err := strconv.Atoi(loStr)
+    if err != nil {
+        return statusRange{}, fmt.Errorf("invalid status %q", s)
+    }
+    hi := lo
+    if isRange {
+        if hi, err = strconv.Atoi(hiStr); err != nil {
+            return statusRange{}, fmt.Errorf("invalid status %q", s)
+        }
+    }
+    if lo < 100 || hi > 599 || lo > hi {
+        return statusRange{}, fmt.Errorf("invalid status range %q", s)
+    }
+    return statusRange{lo, hi}, nil
+}
+
+// parseRedirects parses the redirects option: "follow" for the default
+// limit, "none" (or 0) to evaluate the first response as is, or a maximum
+// number of redirects. The result is stored in Host.Redirects where 0 means
+// the default and -1 means none.
+func parseRedirects(s string) (int, error) {
+    switch strings.ToLower(s) {
+    case "follow", "":
+        return 0, nil
+    case "none", "0", "no":
+        return -1, nil
+    }
+    n, err := strconv.Atoi(s)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid redirects %q", s)
+    }
+    return n, nil
+}
+
+// httpTiming collects the phases of a request. Redirects add up, so the
+// phases describe all hops together.
+type httpTiming struct {
+    dns, connect, tls, ttfb time.Duration
+}
+
+// String formats the phases in milliseconds for the INFO column, leaving out
+// phases that didn't happen (e.g. TLS for plain HTTP).
+func (t httpTiming) String() string {
+    ms := func(d time.Duration) string { return fmt.Sprintf("%.1f", float64(d.Microseconds())/1000) }
+    var parts []string
+    if t.dns > 0 {
+        parts = append(parts, "dns "+ms(t.dns))
+    }
+    if t.connect > 0 {
+        parts = append(parts, "conn "+ms(t.connect))
+    }
+    if t.tls > 0 {
+        parts = append(parts, "tls "+ms(t.tls))
+    }
+    if t.ttfb > 0 {
+        parts = append(parts, "ttfb "+ms(t.ttfb))
+    }
+    return strings.Join(parts, " ")
+}
+
+// probeHTTPGet checks an http:// or https:// target with a GET request. The host
+// is up when the final status lies within the expected range and, if set,
+// the body contains Match and matches Regex. The reply time is the total
+// request time; the INFO column shows the status and the phase breakdown.
+func probeHTTPGet(h Host, fam addrFamily) pingResult {
+    network := "tcp"
+    switch fam {
+    case family4:
+        network = "tcp4"
+    case family6:
+        network = "tcp6"
+    }
+    dialer := &net.Dialer{}
+    transport := &http.Transport{
+        DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+            return dialer.DialContext(ctx, network, addr)
+        },
+        DisableKeepAlives: true,
+        ForceAttemptHTTP2: true,
+    }
+    defer transport.CloseIdleConnections()
+    maxRedirects := h.Redirects
+    if maxRedirects == 0 {
+        maxRedirects = httpDefaultRedirects
+    }
+    client := &http.Client{
+        Transport: transport,
+        CheckRedirect: func(req *http.Request, via []*http.Request) error {
+            switch {
+            case h.Redirects < 0:
+                // Evaluate the redirect response itself
+                return http.ErrUseLastResponse
+            case len(via) > maxRedirects:
+                return errTooManyRedirects
+            }
+            return nil
+        },
+    }
+
+    // Trace hooks may fire from the transport's dialing goroutines, so every
+    // access to the timing state goes through mu.
+    var (
+        mu                                  sync.Mutex
+        timing                              httpTiming
+        dnsStart, connStart, tlsStart, wrote time.Time
+    )
+    record := func(f func()) {
+        mu.Lock()
+        f()
+        mu.Unlock()
+    }
+    trace := &httptrace.ClientTrace{
+        DNSStart: func(httptrace.DNSStartInfo) { record(func() { dnsStart = time.Now() }) },
+        DNSDone:  func(httptrace.DNSDoneInfo) { record(func() { timing.dns += time.Since(dnsStart) }) },
+        ConnectStart: func(string, string) {
+            record(func() { connStart = time.Now() })
+        },
+        ConnectDone: func(string, string, error) {
+            record(func() { timing.connect += time.Since(connStart) })
+        },
+        TLSHandshakeStart: func() { record(func() { tlsStart = time.Now() }) },
+        TLSHandshakeDone: func(tls.ConnectionState, error) {
+            record(func() { timing.tls += time.Since(tlsStart) })
+        },
+        WroteRequest: func(httptrace.WroteRequestInfo) { record(func() { wrote = time.Now() }) },
+        GotFirstResponseByte: func() {
+            record(func() { timing.ttfb += time.Since(wrote) })
+        },
+    }
+    phases := func() string {
+        mu.Lock()
+        defer mu.Unlock()
+        return timing.String()
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, h.Host, nil)
+    if err != nil {
+        return failed(failOther, "invalid target", "")
+    }
+    req.Header.Set("User-Agent", "mping")
+    start := time.Now()
+    resp, err := client.Do(req)
+    if err != nil {
+        return httpFailure(err, phases())
+    }
+    defer resp.Body.Close()
+    var body []byte
+    if h.Match != "" || h.Regex != nil {
+        body, err = io.ReadAll(io.LimitReader(resp.Body, httpMaxBody))
+    } else {
+        _, err = io.Copy(io.Discard, io.LimitReader(resp.Body, httpMaxBody))
+    }
+    elapsed := time.Since(start)
+    info := strconv.Itoa(resp.StatusCode)
+    if t := phases(); t != "" {
+        info += " " + t
+    }
+    if err != nil {
+        return httpFailure(err, info)
+    }
+    if !h.Expect.contains(resp.StatusCode) {
+        return failed(failCheck, "status "+strconv.Itoa(resp.StatusCode), info)
+    }
+    if h.Match != "" && !strings.Contains(string(body), h.Match) {
+        return failed(failCheck, "no match", info)
+    }
+    if h.Regex != nil && !h.Regex.Match(body) {
+        return failed(failCheck, "no match", info)
+    }
+    return pingResult{status: true, reply: float64(elapsed.Microseconds()) / 1000, info: info}
+}
+
+// httpFailure returns the result of a request that failed with err. A
+// certificate that doesn't verify or too many redirects fail the check;
+// everything else is classified like any network error.
+func httpFailure(err error, info string) pingResult {
+    var certErr *tls.CertificateVerificationError
+    if errors.As(err, &certErr) {
+        return failed(failCheck, "cert", info)
+    }
+    if errors.Is(err, errTooManyRedirects) {
+        return failed(failCheck, errTooManyRedirects.Error(), info)
+    }
+    return failedErr(err, info)
+}
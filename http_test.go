@@ -0,0 +1,181 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// newCheckServer starts a local server with the paths the HTTP check tests
+// probe.
+func newCheckServer(t *testing.T) *httptest.Server {
+    t.Helper()
+    mux := http.NewServeMux()
+    mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "service ready, build 1234")
+    })
+    mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusCreated)
+    })
+    mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+        http.NotFound(w, r)
+    })
+    mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    })
+    mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/ok", http.StatusFound)
+    })
+    mux.HandleFunc("/twice", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/moved", http.StatusFound)
+    })
+    mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/loop", http.StatusFound)
+    })
+    mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+        time.Sleep(50 * time.Millisecond)
+        fmt.Fprint(w, "late")
+    })
+    srv := httptest.NewServer(mux)
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+// checkHost parses a host spec for the HTTP check, with the server's URL in
+// place of the %s.
+func checkHost(t *testing.T, srv *httptest.Server, spec string) Host {
+    t.Helper()
+    h, err := parseHostSpec(fmt.Sprintf(spec, srv.URL))
+    if err != nil {
+        t.Fatalf("parseHostSpec(%q): %v", spec, err)
+    }
+    if h.Probe != probeHTTP {
+        t.Fatalf("parseHostSpec(%q): probe %v, want HTTP", spec, h.Probe)
+    }
+    return h
+}
+
+func TestProbeHTTPGet(t *testing.T) {
+    srv := newCheckServer(t)
+    tests := []struct {
+        spec   string
+        up     bool
+        reason string // for failures
+        status string // leading word of INFO
+    }{
+        // Status ranges
+        {"%s/ok", true, "", "200"},
+        {"%s/created", true, "", "201"},
+        {"%s/missing", false, "status 404", "404"},
+        {"%s/missing status=404", true, "", "404"},
+        {"%s/missing status=4xx", true, "", "404"},
+        {"%s/unavailable status=200-299", false, "status 503", "503"},
+        {"%s/created status=200", false, "status 201", "201"},
+
+        // Body substring and regular expression
+        {"%s/ok match=ready", true, "", "200"},
+        {"%s/ok match=down", false, "no match", "200"},
+        // Option values are unescaped like a query, so + is written %2B
+        // (doubled here for Sprintf)
+        {`%s/ok regex=build\s\d%%2B`, true, "", "200"},
+        {`%s/ok regex=^build`, false, "no match", "200"},
+        {`%s/ok match=ready regex=build\s\d{5}`, false, "no match", "200"},
+
+        // Redirect policy
+        {"%s/moved", true, "", "200"},
+        {"%s/moved redirects=none", true, "", "302"},
+        {"%s/moved redirects=none status=200", false, "status 302", "302"},
+        {"%s/twice redirects=2", true, "", "200"},
+        {"%s/twice redirects=1", false, "too many redirects", ""},
+        {"%s/loop redirects=2", false, "too many redirects", ""},
+        {"%s/loop", false, "too many redirects", ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.spec, func(t *testing.T) {
+            h := checkHost(t, srv, tt.spec)
+            if again, err := parseHostSpec(h.spec()); err != nil || again.spec() != h.spec() {
+                t.Errorf("spec %q doesn't parse back: %v", h.spec(), err)
+            }
+            res := probeHTTPGet(h, h.Family)
+            if res.status != tt.up {
+                t.Fatalf("status = %v (reason %q), want %v", res.status, res.reason, tt.up)
+            }
+            if !tt.up && res.reason != tt.reason {
+                t.Errorf("reason = %q, want %q", res.reason, tt.reason)
+            }
+            if !tt.up && res.fail != failCheck {
+                t.Errorf("fail = %v, want %v", res.fail, failCheck)
+            }
+            if tt.up && res.reply <= 0 {
+                t.Errorf("reply = %v, want the request time", res.reply)
+            }
+            if tt.status != "" && !strings.HasPrefix(res.info, tt.status+" ") {
+                t.Errorf("info = %q, want it to start with %q", res.info, tt.status)
+            }
+        })
+    }
+}
+
+// phaseMillis returns the duration of the named phase in an INFO column, or
+// -1 if the phase isn't listed.
+func phaseMillis(info, phase string) float64 {
+    f := strings.Fields(info)
+    for i := 0; i+1 < len(f); i++ {
+        if f[i] == phase {
+            var v float64
+            if _, err := fmt.Sscan(f[i+1], &v); err == nil {
+                return v
+            }
+        }
+    }
+    return -1
+}
+
+func TestProbeHTTPGetTiming(t *testing.T) {
+    srv := newCheckServer(t)
+    h := checkHost(t, srv, "%s/slow")
+    res := probeHTTPGet(h, h.Family)
+    if !res.status {
+        t.Fatalf("status = false (reason %q), want true", res.reason)
+    }
+    // A literal address needs no lookup and plain HTTP no handshake.
+    if v := phaseMillis(res.info, "dns"); v != -1 {
+        t.Errorf("info %q has a DNS phase for an IP address", res.info)
+    }
+    if v := phaseMillis(res.info, "tls"); v != -1 {
+        t.Errorf("info %q has a TLS phase for plain HTTP", res.info)
+    }
+    if v := phaseMillis(res.info, "conn"); v < 0 {
+        t.Errorf("info %q lacks the connect phase", res.info)
+    }
+    ttfb := phaseMillis(res.info, "ttfb")
+    if ttfb < 50 {
+        t.Errorf("info %q: ttfb %v ms, want at least the handler's 50 ms", res.info, ttfb)
+    }
+    if res.reply < ttfb {
+        t.Errorf("reply %v ms is shorter than ttfb %v ms", res.reply, ttfb)
+    }
+}
+
+func TestProbeHTTPGetTLS(t *testing.T) {
+    srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    // The rejected handshake is expected; keep the server quiet about it
+    srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+    srv.StartTLS()
+    t.Cleanup(srv.Close)
+    h := checkHost(t, srv, "%s/")
+    res := probeHTTPGet(h, h.Family)
+    // The test server's certificate isn't trusted, which fails the check
+    // after the handshake was timed.
+    if res.status || res.reason != "cert" {
+        t.Fatalf("status %v reason %q, want a failed cert check", res.status, res.reason)
+    }
+    if v := phaseMillis(res.info, "tls"); v < 0 {
+        t.Errorf("info %q lacks the TLS phase", res.info)
+    }
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+    "context"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "sync"
+    "time"
+
+    "golang.org/x/net/icmp"
+    "golang.org/x/net/ipv4"
+    "golang.org/x/net/ipv6"
+)
+
+// icmpTimeout bounds how long a single echo request may stay outstanding
+// before it is reported as lost.
+const icmpTimeout = time.Second
+
+// errICMPUnavailable is returned when neither a datagram nor a raw ICMP
+// socket could be opened. Callers fall back to the system ping binary.
+var errICMPUnavailable = errors.New("icmp sockets unavailable")
+
+// icmpReply is what the receive loop hands back to a waiting sender once
+// the matching echo reply, or an ICMP error quoting the request, arrived.
+type icmpReply struct {
+    rtt time.Duration
+    ttl int
+
+    // For ICMP errors: the kind of error, its code and the router that sent
+    // it. fail is failNone for an echo reply.
+    fail failureKind
+    code int
+    from net.IP
+}
+
+// reason describes an ICMP error for the REASON column, e.g.
+// "host unreachable from 10.0.0.1 (code 1)".
+func (r icmpReply) reason(v6 bool) string {
+    what := r.fail.String()
+    if r.fail == failUnreachable {
+        names := icmpUnreachCodes4
+        if v6 {
+            names = icmpUnreachCodes6
+        }
+        if name, ok := names[r.code]; ok {
+            what = name
+        }
+    }
+    if r.from != nil {
+        what += " from " + r.from.String()
+    }
+    return fmt.Sprintf("%s (code %d)", what, r.code)
+}
+
+// Names of the destination unreachable codes (RFC 792, RFC 1812 and
+// RFC 4443).
+var (
+    icmpUnreachCodes4 = map[int]string{
+        0:  "net unreachable",
+        1:  "host unreachable",
+        2:  "protocol unreachable",
+        3:  "port unreachable",
+        4:  "fragmentation needed",
+        5:  "source route failed",
+        6:  "net unknown",
+        7:  "host unknown",
+        9:  "net prohibited",
+        10: "host prohibited",
+        11: "net unreachable for TOS",
+        12: "host unreachable for TOS",
+        13: "admin prohibited",
+    }
+    icmpUnreachCodes6 = map[int]string{
+        0: "no route",
+        1: "admin prohibited",
+        2: "beyond scope",
+        3: "address unreachable",
+        4: "port unreachable",
+        5: "source policy failed",
+        6: "reject route",
+    }
+)
+
+// icmpPending tracks a single outstanding echo request.
+type icmpPending struct {
+    dst  net.IP
+    sent time.Time
+    ch   chan icmpReply
+}
+
+// icmpEngine multiplexes echo requests for one address family over a single
+// ICMP socket. Requests are tagged with the engine's identifier and a
+// per‑engine sequence number; a background goroutine reads every reply and
+// dispatches it to the sender waiting on that sequence number.
+type icmpEngine struct {
+    conn     *icmp.PacketConn
+    proto    int  // IANA protocol number used to parse replies (1 or 58)
+    datagram bool // true for unprivileged "udp4"/"udp6" sockets
+    recvErr  bool // ICMP errors arrive on the socket's error queue
+    v6       bool
+    id       int
+
+    // done is closed once the receive loop stopped and the socket is
+    // closed. The engine is then replaced on next use.
+    done chan struct{}
+
+    mu      sync.Mutex
+    seq     uint16
+    pending map[uint16]*icmpPending
+}
+
+var (
+    icmpMu             sync.Mutex
+    icmpEng4, icmpEng6 *icmpEngine
+    icmpErr4, icmpErr6 error
+)
+
+// icmpEngineFor returns the shared engine for the given address family,
+// opening its socket on first use and again after the previous socket
+// failed. Unprivileged datagram sockets are tried first (Linux with
+// ping_group_range, macOS); raw sockets are used when the process is
+// privileged. Once no socket could be opened, errICMPUnavailable is returned
+// for good and callers use the system ping binary.
+func icmpEngineFor(v6 bool) (*icmpEngine, error) {
+    icmpMu.Lock()
+    defer icmpMu.Unlock()
+    eng, err := &icmpEng4, &icmpErr4
+    if v6 {
+        eng, err = &icmpEng6, &icmpErr6
+    }
+    if *err == nil && (*eng == nil || (*eng).dead()) {
+        *eng, *err = newICMPEngine(v6)
+    }
+    return *eng, *err
+}
+
+// icmpAvailable reports whether pings over fam can use the built‑in engine.
+// With familyAuto the resolved address picks the engine, so either will do;
+// pingAddr still falls back to the system ping command for an address whose
+// family has no socket.
+func icmpAvailable(fam addrFamily) bool {
+    switch fam {
+    case family4:
+        _, err := icmpEngineFor(false)
+        return err == nil
+    case family6:
+        _, err := icmpEngineFor(true)
+        return err == nil
+    }
+    return icmpAvailable(family4) || icmpAvailable(family6)
+}
+
+// newICMPEngine opens an ICMP socket for the requested family and starts its
+// receive loop.
+func newICMPEngine(v6 bool) (*icmpEngine, error) {
+    type candidate struct {
+        network, address string
+        datagram         bool
+    }
+    candidates := []candidate{{"udp4", "0.0.0.0", true}, {"ip4:icmp", "0.0.0.0", false}}
+    proto := 1
+    if v6 {
+        candidates = []candidate{{"udp6", "::", true}, {"ip6:ipv6-icmp", "::", false}}
+        proto = 58
+    }
+    for _, c := range candidates {
+        conn, err := icmp.ListenPacket(c.network, c.address)
+        if err != nil {
+            continue
+        }
+        e := &icmpEngine{
+            conn:     conn,
+            proto:    proto,
+            datagram: c.datagram,
+            v6:       v6,
+            id:       os.Getpid() & 0xffff,
+            done:     make(chan struct{}),
+            pending:  make(map[uint16]*icmpPending),
+        }
+        // Datagram sockets have their echo identifier rewritten by the
+        // kernel to the socket's local port, so that's what replies carry.
+        if c.datagram {
+            if ua, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+                e.id = ua.Port
+            }
+        }
+        // Ask for the TTL/hop limit of incoming packets. Failure here is not
+        // fatal; replies simply report a TTL of 0.
+        if v6 {
+            _ = conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+        } else {
+            _ = conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+        }
+        // Datagram sockets only learn about ICMP errors if asked to.
+        if c.datagram {
+            e.recvErr = e.enableRecvErr()
+        }
+        go e.receive()
+        return e, nil
+    }
+    return nil, errICMPUnavailable
+}
+
+// receive reads replies until the socket fails and hands each matching echo
+// reply to the goroutine waiting on it.
+func (e *icmpEngine) receive() {
+    buf := make([]byte, 1500)
+    for {
+        var (
+            n    int
+            peer net.Addr
+            ttl  int
+            err  error
+        )
+        if e.v6 {
+            var cm *ipv6.ControlMessage
+            n, cm, peer, err = e.conn.IPv6PacketConn().ReadFrom(buf)
+            if cm != nil {
+                ttl = cm.HopLimit
+            }
+        } else {
+            var cm *ipv4.ControlMessage
+            n, cm, peer, err = e.conn.IPv4PacketConn().ReadFrom(buf)
+            if cm != nil {
+                ttl = cm.TTL
+            }
+        }
+        if err != nil {
+            var ne net.Error
+            if errors.As(err, &ne) && ne.Timeout() {
+                continue
+            }
+            // Datagram sockets report a queued ICMP error by failing the
+            // read, see readErrQueue.
+            if e.recvErr && e.readErrQueue(err) {
+                continue
+            }
+            e.stop()
+            return
+        }
+        now := time.Now()
+        msg, err := icmp.ParseMessage(e.proto, buf[:n])
+        if err != nil {
+            continue
+        }
+        reply := icmpReply{ttl: ttl}
+        var seq uint16
+        var dst net.IP
+        switch msg.Type {
+        case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+            echo, ok := msg.Body.(*icmp.Echo)
+            if !ok || echo.ID != e.id {
+                continue
+            }
+            seq, dst = uint16(echo.Seq), addrIP(peer)
+        case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable,
+            ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+            // Errors quote the datagram that caused them; only those quoting
+            // one of our echo requests are of interest.
+            var data []byte
+            switch body := msg.Body.(type) {
+            case *icmp.DstUnreach:
+                data, reply.fail = body.Data, failUnreachable
+            case *icmp.TimeExceeded:
+                data, reply.fail = body.Data, failTTLExceeded
+            }
+            var ok bool
+            seq, dst, ok = e.quotedEcho(data)
+            if !ok {
+                continue
+            }
+            reply.code, reply.from = msg.Code, addrIP(peer)
+        default:
+            continue
+        }
+        e.deliver(seq, dst, reply, now)
+    }
+}
+
+// deliver hands a reply that arrived at now to the sender of the echo
+// request with the given sequence number, if that request went to dst and
+// is still waiting.
+func (e *icmpEngine) deliver(seq uint16, dst net.IP, reply icmpReply, now time.Time) {
+    e.mu.Lock()
+    p, ok := e.pending[seq]
+    if ok && p.dst.Equal(dst) {
+        delete(e.pending, seq)
+    } else {
+        ok = false
+    }
+    e.mu.Unlock()
+    if ok {
+        reply.rtt = now.Sub(p.sent)
+        p.ch <- reply
+    }
+}
+
+// quotedEcho extracts the sequence number and destination of the echo
+// request quoted in an ICMP error, i.e. the original IP header followed by
+// at least the first eight bytes of our ICMP message. ok is false if the
+// quoted datagram isn't one of this engine's echo requests.
+//
+// Only raw sockets receive these errors as packets; datagram sockets get
+// them from the error queue instead, see readErrQueue.
+func (e *icmpEngine) quotedEcho(data []byte) (seq uint16, dst net.IP, ok bool) {
+    var inner []byte
+    if e.v6 {
+        // Extension headers are not expected on our own echo requests.
+        if len(data) < ipv6.HeaderLen+8 || data[6] != 58 {
+            return 0, nil, false
+        }
+        dst = net.IP(data[24:40])
+        inner = data[ipv6.HeaderLen:]
+    } else {
+        if len(data) < ipv4.HeaderLen {
+            return 0, nil, false
+        }
+        hl := int(data[0]&0x0f) * 4
+        if hl < ipv4.HeaderLen || len(data) < hl+8 || data[9] != 1 {
+            return 0, nil, false
+        }
+        dst = net.IP(data[16:20])
+        inner = data[hl:]
+    }
+    request := byte(ipv4.ICMPTypeEcho)
+    if e.v6 {
+        request = byte(ipv6.ICMPTypeEchoRequest)
+    }
+    if inner[0] != request || int(binary.BigEndian.Uint16(inner[4:6])) != e.id {
+        return 0, nil, false
+    }
+    return binary.BigEndian.Uint16(inner[6:8]), dst, true
+}
+
+// stop closes the socket after reading from it failed and marks the engine
+// dead. Outstanding requests are dropped; their senders return
+// errICMPUnavailable so the round falls back to the system ping binary, and
+// the next round opens a fresh socket.
+func (e *icmpEngine) stop() {
+    close(e.done)
+    e.conn.Close()
+    e.mu.Lock()
+    e.pending = make(map[uint16]*icmpPending)
+    e.mu.Unlock()
+}
+
+// dead reports whether the engine stopped.
+func (e *icmpEngine) dead() bool {
+    select {
+    case <-e.done:
+        return true
+    default:
+        return false
+    }
+}
+
+// echo sends a single echo request to dst and waits for the matching reply.
+// It returns ok=false if no reply arrived within the timeout. An ICMP error
+// answering the request is returned as a reply with fail set. If the engine
+// stops meanwhile, errICMPUnavailable is returned.
+func (e *icmpEngine) echo(ctx context.Context, dst net.IP) (icmpReply, bool, error) {
+    ch := make(chan icmpReply, 1)
+    e.mu.Lock()
+    e.seq++
+    seq := e.seq
+    p := &icmpPending{dst: dst, ch: ch}
+    e.pending[seq] = p
+    e.mu.Unlock()
+    defer func() {
+        e.mu.Lock()
+        if e.pending[seq] == p {
+            delete(e.pending, seq)
+        }
+        e.mu.Unlock()
+    }()
+
+    var typ icmp.Type = ipv4.ICMPTypeEcho
+    if e.v6 {
+        typ = ipv6.ICMPTypeEchoRequest
+    }
+    // Pad the payload to the classic 56 bytes so we look like a normal ping.
+    data := make([]byte, 56)
+    copy(data, "mping")
+    msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: e.id, Seq: int(seq), Data: data}}
+    // The kernel computes the ICMPv6 checksum, so no pseudo header is needed.
+    wb, err := msg.Marshal(nil)
+    if err != nil {
+        return icmpReply{}, false, err
+    }
+    var addr net.Addr = &net.IPAddr{IP: dst}
+    if e.datagram {
+        addr = &net.UDPAddr{IP: dst}
+    }
+    e.mu.Lock()
+    p.sent = time.Now()
+    e.mu.Unlock()
+    if _, err := e.conn.WriteTo(wb, addr); err != nil {
+        if e.dead() {
+            return icmpReply{}, false, errICMPUnavailable
+        }
+        return icmpReply{}, false, err
+    }
+    select {
+    case r := <-ch:
+        return r, true, nil
+    case <-e.done:
+        return icmpReply{}, false, errICMPUnavailable
+    case <-ctx.Done():
+        return icmpReply{}, false, nil
+    }
+}
+
+// addrIP extracts the IP from the address types returned by ICMP sockets.
+func addrIP(a net.Addr) net.IP {
+    switch v := a.(type) {
+    case *net.UDPAddr:
+        return v.IP
+    case *net.IPAddr:
+        return v.IP
+    }
+    return nil
+}
+
+// pingHostNative pings host once over the given address family using the
+// built‑in ICMP engine. familyBoth is not accepted here; callers probe each
+// family separately. It returns errICMPUnavailable if no ICMP socket could be
+// opened or the socket failed during the echo, in which case the caller
+// should fall back to the system ping command.
+func pingHostNative(host string, fam addrFamily) (pingResult, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+    defer cancel()
+    ip, err := resolveFor(ctx, host, fam)
+    if err != nil {
+        return failedErr(err, ""), nil
+    }
+    eng, err := icmpEngineFor(ip.To4() == nil)
+    if err != nil {
+        return pingResult{}, err
+    }
+    if ip4 := ip.To4(); ip4 != nil {
+        ip = ip4
+    }
+    ectx, ecancel := context.WithTimeout(ctx, icmpTimeout)
+    defer ecancel()
+    reply, ok, err := eng.echo(ectx, ip)
+    if errors.Is(err, errICMPUnavailable) {
+        return pingResult{}, err
+    }
+    if err != nil {
+        return failedErr(err, ""), nil
+    }
+    if !ok {
+        return failed(failTimeout, "", ""), nil
+    }
+    if reply.fail != failNone {
+        return failed(reply.fail, reply.reason(eng.v6), ""), nil
+    }
+    return pingResult{status: true, reply: float64(reply.rtt.Microseconds()) / 1000, info: ttlInfo(reply.ttl)}, nil
+}
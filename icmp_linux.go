@@ -0,0 +1,181 @@
+//go:build linux
+
+package main
+
+import (
+    "encoding/binary"
+    "errors"
+    "net"
+    "syscall"
+    "time"
+)
+
+// Unprivileged datagram ICMP sockets on Linux don't receive ICMP errors as
+// packets. With IP_RECVERR (IPV6_RECVERR) set, the kernel instead queues each
+// error on the socket's error queue, where it carries the type, code and
+// sender of the ICMP error along with the echo request it answers, and fails
+// the next regular read with the matching errno.
+
+// Origins of a queued error, from linux/errqueue.h.
+const (
+    eeOriginICMP  = 2
+    eeOriginICMP6 = 3
+)
+
+// rawConn returns the socket underlying the engine's connection.
+func (e *icmpEngine) rawConn() (syscall.RawConn, error) {
+    var pc net.PacketConn
+    if e.v6 {
+        pc = e.conn.IPv6PacketConn().PacketConn
+    } else {
+        pc = e.conn.IPv4PacketConn().PacketConn
+    }
+    sc, ok := pc.(syscall.Conn)
+    if !ok {
+        return nil, errors.New("icmp socket has no file descriptor")
+    }
+    return sc.SyscallConn()
+}
+
+// enableRecvErr asks the kernel to queue ICMP errors for the engine's
+// socket. It reports whether that worked.
+func (e *icmpEngine) enableRecvErr() bool {
+    rc, err := e.rawConn()
+    if err != nil {
+        return false
+    }
+    level, opt := syscall.IPPROTO_IP, syscall.IP_RECVERR
+    if e.v6 {
+        level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR
+    }
+    var serr error
+    if err := rc.Control(func(fd uintptr) {
+        serr = syscall.SetsockoptInt(int(fd), level, opt, 1)
+    }); err != nil {
+        return false
+    }
+    return serr == nil
+}
+
+// queuedErrnos are the read errors by which the kernel announces a queued
+// ICMP error: what destination unreachable, time exceeded and parameter
+// problem messages translate to.
+var queuedErrnos = []syscall.Errno{
+    syscall.ENETUNREACH, syscall.EHOSTUNREACH, syscall.ENOPROTOOPT, syscall.ECONNREFUSED,
+    syscall.EMSGSIZE, syscall.EOPNOTSUPP, syscall.EHOSTDOWN, syscall.ENONET,
+    syscall.EACCES, syscall.EPROTO,
+}
+
+// readErrQueue drains the socket's error queue after a read failed with
+// err, handing each ICMP error that answers a pending echo request to its
+// sender. It reports whether err was the announcement of a queued error,
+// in which case the socket is fine and reading goes on.
+func (e *icmpEngine) readErrQueue(err error) bool {
+    var errno syscall.Errno
+    if !errors.As(err, &errno) {
+        return false
+    }
+    rc, rerr := e.rawConn()
+    if rerr != nil {
+        return false
+    }
+    drained := 0
+    buf := make([]byte, 512)
+    oob := make([]byte, 512)
+    rerr = rc.Read(func(fd uintptr) bool {
+        for {
+            n, oobn, _, from, err := syscall.Recvmsg(int(fd), buf, oob, syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+            if err != nil {
+                // EAGAIN: the queue is empty
+                return true
+            }
+            drained++
+            e.queuedError(buf[:n], oob[:oobn], from, time.Now())
+        }
+    })
+    if rerr != nil {
+        return false
+    }
+    if drained > 0 {
+        return true
+    }
+    for _, q := range queuedErrnos {
+        if errno == q {
+            return true
+        }
+    }
+    return false
+}
+
+// queuedError dispatches one message from the error queue. payload is the
+// ICMP header of the echo request the error answers, from the address it
+// was sent to; oob holds the extended error.
+func (e *icmpEngine) queuedError(payload, oob []byte, from syscall.Sockaddr, now time.Time) {
+    if len(payload) < 8 || int(binary.BigEndian.Uint16(payload[4:6])) != e.id {
+        return
+    }
+    msgs, err := syscall.ParseSocketControlMessage(oob)
+    if err != nil {
+        return
+    }
+    level, typ := syscall.IPPROTO_IP, syscall.IP_RECVERR
+    if e.v6 {
+        level, typ = syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR
+    }
+    for _, m := range msgs {
+        if int(m.Header.Level) != level || int(m.Header.Type) != typ {
+            continue
+        }
+        reply, ok := e.extendedError(m.Data)
+        if ok {
+            e.deliver(binary.BigEndian.Uint16(payload[6:8]), sockaddrIP(from), reply, now)
+        }
+        return
+    }
+}
+
+// extendedError turns a struct sock_extended_err, followed by the address
+// of the router that sent the error, into a reply. ok is false for errors
+// that didn't come from an ICMP destination unreachable or time exceeded
+// message.
+func (e *icmpEngine) extendedError(data []byte) (icmpReply, bool) {
+    // struct sock_extended_err {
+    //     __u32 ee_errno; __u8 ee_origin; __u8 ee_type; __u8 ee_code;
+    //     __u8 ee_pad; __u32 ee_info; __u32 ee_data;
+    // };
+    const size = 16
+    if len(data) < size {
+        return icmpReply{}, false
+    }
+    origin, typ, code := data[4], data[5], data[6]
+    var reply icmpReply
+    switch {
+    case !e.v6 && origin == eeOriginICMP && typ == 3, e.v6 && origin == eeOriginICMP6 && typ == 1:
+        reply.fail = failUnreachable
+    case !e.v6 && origin == eeOriginICMP && typ == 11, e.v6 && origin == eeOriginICMP6 && typ == 3:
+        reply.fail = failTTLExceeded
+    default:
+        return icmpReply{}, false
+    }
+    reply.code = int(code)
+    // The offender is a struct sockaddr_in or sockaddr_in6.
+    off := data[size:]
+    switch {
+    case !e.v6 && len(off) >= 8 && binary.NativeEndian.Uint16(off) == syscall.AF_INET:
+        reply.from = net.IP(append([]byte(nil), off[4:8]...))
+    case e.v6 && len(off) >= 24 && binary.NativeEndian.Uint16(off) == syscall.AF_INET6:
+        reply.from = net.IP(append([]byte(nil), off[8:24]...))
+    }
+    return reply, true
+}
+
+// sockaddrIP extracts the IP of an IPv4 or IPv6 socket address.
+func sockaddrIP(sa syscall.Sockaddr) net.IP {
+    switch v := sa.(type) {
+    case *syscall.SockaddrInet4:
+        return net.IP(append([]byte(nil), v.Addr[:]...))
+    case *syscall.SockaddrInet6:
+        return net.IP(append([]byte(nil), v.Addr[:]...))
+    }
+    return nil
+}
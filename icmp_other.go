@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+// Only Linux queues ICMP errors for datagram sockets; elsewhere probes that
+// draw an ICMP error on a datagram socket time out.
+
+// enableRecvErr reports that ICMP errors aren't available.
+func (e *icmpEngine) enableRecvErr() bool {
+    return false
+}
+
+// readErrQueue reports that err is a real read error.
+func (e *icmpEngine) readErrQueue(err error) bool {
+    return false
+}
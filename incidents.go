@@ -0,0 +1,297 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strings"
+    "time"
+
+    "github.com/charmbracelet/bubbles/textinput"
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// An incident is an outage of one host: it begins when the host goes from UP
+// or DEGRADED to DOWN or ERROR, or when its first result after a start or
+// reload already is DOWN or ERROR, and ends when it is back up. Incidents are
+// rebuilt from the state changes in the store on startup and followed live
+// from then on. The incidents screen lists them newest first and filters
+// them by host and time.
+
+// incident is one outage.
+type incident struct {
+    key        string    // host spec, as in the store
+    start, end time.Time // end is zero while the outage lasts
+    state      hostState // DOWN or ERROR when it began
+    reason     string    // why it began
+}
+
+// host returns the target of the incident's host, without options.
+func (in incident) host() string {
+    host, _, _ := strings.Cut(in.key, " ")
+    return host
+}
+
+// duration returns how long the incident lasted, or has lasted by now.
+func (in incident) duration(now time.Time) time.Duration {
+    if in.end.IsZero() {
+        return now.Sub(in.start)
+    }
+    return in.end.Sub(in.start)
+}
+
+// noteState follows a host's state into the incident log. changed tells
+// whether the state just changed from from to to; rounds that keep the state
+// only end an incident, for instance the first round after a restart. A
+// host leaving PENDING for DOWN or ERROR starts an incident, as its outage
+// began before mping could tell.
+func (m *model) noteState(key string, from, to hostState, changed bool, reason string, at time.Time) {
+    if m.openIncidents == nil {
+        m.openIncidents = make(map[string]int)
+    }
+    up := to == stateUp || to == stateDegraded
+    if k, ok := m.openIncidents[key]; ok {
+        if up {
+            m.incidents[k].end = at
+            delete(m.openIncidents, key)
+        }
+        return
+    }
+    wasUp := changed && (from == stateUp || from == stateDegraded)
+    if (wasUp || from == statePending) && (to == stateDown || to == stateError) {
+        m.openIncidents[key] = len(m.incidents)
+        m.incidents = append(m.incidents, incident{key: key, start: at, state: to, reason: reason})
+    }
+}
+
+// closeUnlisted ends the ongoing incidents of hosts that are no longer
+// listed at the given time, when mping stopped following them: a host that
+// was deleted or reloaded away while down, or whose options were edited,
+// which gives it a new key. Should it still be down under its new key, its
+// first result starts a new incident.
+func (m *model) closeUnlisted(at time.Time) {
+    listed := make(map[string]bool, len(m.hosts))
+    for _, h := range m.hosts {
+        listed[h.spec()] = true
+    }
+    for key, k := range m.openIncidents {
+        if !listed[key] {
+            m.incidents[k].end = at
+            delete(m.openIncidents, key)
+        }
+    }
+}
+
+// incidentRange is a selectable time filter of the incidents screen.
+type incidentRange struct {
+    label string
+    span  time.Duration // 0 for all incidents
+}
+
+// incidentRanges lists the time filters in the order the number keys select
+// them.
+var incidentRanges = []incidentRange{
+    {"all", 0},
+    {"24h", 24 * time.Hour},
+    {"7d", 7 * 24 * time.Hour},
+    {"30d", 30 * 24 * time.Hour},
+}
+
+// filteredIncidents returns the incidents matching the host filter that
+// overlap the selected time range, newest first.
+func (m model) filteredIncidents(now time.Time) []incident {
+    filter := strings.ToLower(strings.TrimSpace(m.incidentFilter.Value()))
+    span := incidentRanges[m.incidentRange].span
+    var out []incident
+    for k := len(m.incidents) - 1; k >= 0; k-- {
+        in := m.incidents[k]
+        if filter != "" && !strings.Contains(strings.ToLower(in.key), filter) {
+            continue
+        }
+        if span > 0 && !in.end.IsZero() && in.end.Before(now.Add(-span)) {
+            continue
+        }
+        out = append(out, in)
+    }
+    return out
+}
+
+// openIncidentsView switches to the incidents screen.
+func (m *model) openIncidentsView() {
+    if m.incidentFilter.Placeholder == "" {
+        m.incidentFilter = textinput.New()
+        m.incidentFilter.Placeholder = "host"
+    }
+    m.incidentOffset = 0
+    m.mode = modeIncidents
+}
+
+// updateIncidents handles keys on the incidents screen: / edits the host
+// filter, the number keys (or t) pick the time range, the arrow keys scroll
+// and Esc or q go back. While the filter is edited, Enter keeps it and Esc
+// clears it.
+func (m model) updateIncidents(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    if m.incidentFilter.Focused() {
+        switch msg.String() {
+        case "ctrl+c":
+            m.quitting = true
+            return m, tea.Quit
+        case "enter":
+            m.incidentFilter.Blur()
+        case "esc":
+            m.incidentFilter.SetValue("")
+            m.incidentFilter.Blur()
+        default:
+            var cmd tea.Cmd
+            m.incidentFilter, cmd = m.incidentFilter.Update(msg)
+            m.incidentOffset = 0
+            return m, cmd
+        }
+        m.incidentOffset = 0
+        return m, nil
+    }
+    switch msg.String() {
+    case "ctrl+c":
+        m.quitting = true
+        return m, tea.Quit
+    case "esc", "q", "Q", "i", "I":
+        m.mode = modeList
+    case "/", "f", "F":
+        m.incidentFilter.Focus()
+        return m, nil
+    case "h", "H":
+        // Filter by the host selected in the list
+        if len(m.hosts) > 0 {
+            m.incidentFilter.SetValue(m.hosts[m.cursor].Host)
+            m.incidentOffset = 0
+        }
+    case "up", "k":
+        m.incidentOffset--
+    case "down", "j":
+        m.incidentOffset++
+    case "pgup":
+        m.incidentOffset -= m.incidentRows()
+    case "pgdown":
+        m.incidentOffset += m.incidentRows()
+    case "home":
+        m.incidentOffset = 0
+    case "end":
+        m.incidentOffset = math.MaxInt32
+    case "t", "T", "tab":
+        m.incidentRange = (m.incidentRange + 1) % len(incidentRanges)
+        m.incidentOffset = 0
+    default:
+        for i := range incidentRanges {
+            if msg.String() == fmt.Sprint(i+1) {
+                m.incidentRange = i
+                m.incidentOffset = 0
+            }
+        }
+    }
+    // The offset is clamped when the list is drawn, as it may shrink or
+    // grow in between.
+    if m.incidentOffset < 0 {
+        m.incidentOffset = 0
+    }
+    return m, nil
+}
+
+// incidentRows returns the number of incidents that fit the terminal.
+func (m model) incidentRows() int {
+    return max(m.height-7, 3)
+}
+
+// shortDuration formats d compactly, e.g. "45s", "12m30s", "3h05m" or
+// "2d04h".
+func shortDuration(d time.Duration) string {
+    s := int64(d.Round(time.Second) / time.Second)
+    switch {
+    case s < 60:
+        return fmt.Sprintf("%ds", s)
+    case s < 3600:
+        return fmt.Sprintf("%dm%02ds", s/60, s%60)
+    case s < 86400:
+        return fmt.Sprintf("%dh%02dm", s/3600, s%3600/60)
+    }
+    return fmt.Sprintf("%dd%02dh", s/86400, s%86400/3600)
+}
+
+// incidentsView renders the incidents screen.
+func (m model) incidentsView() string {
+    now := m.now()
+    list := m.filteredIncidents(now)
+    titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Bold(true)
+    dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+    ongoingStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+
+    // Hosts still being monitored; an open incident of any other host has
+    // no known end.
+    monitored := make(map[string]bool, len(m.hosts))
+    for _, h := range m.hosts {
+        monitored[h.spec()] = true
+    }
+
+    var out strings.Builder
+    // Title with a summary of the listed incidents.
+    var ongoing int
+    var down time.Duration
+    for _, in := range list {
+        switch {
+        case !in.end.IsZero():
+            down += in.duration(now)
+        case monitored[in.key]:
+            ongoing++
+            down += in.duration(now)
+        }
+    }
+    out.WriteString(titleStyle.Render("Incidents") + "   " +
+        fmt.Sprintf("%d listed, %d ongoing, %s down in total", len(list), ongoing, shortDuration(down)) + "\n\n")
+
+    // Filters.
+    var tabs []string
+    for k, r := range incidentRanges {
+        label := fmt.Sprintf(" %d %s ", k+1, r.label)
+        if k == m.incidentRange {
+            label = lipgloss.NewStyle().Reverse(true).Render(label)
+        }
+        tabs = append(tabs, label)
+    }
+    out.WriteString("Host: " + m.incidentFilter.View() + "   " + strings.Join(tabs, " ") + "\n\n")
+
+    // Table, newest incident first.
+    const layout = "2006-01-02 15:04:05"
+    wHost, wReason := len("HOST"), len("REASON")
+    for _, in := range list {
+        wHost = max(wHost, len(in.host()))
+        wReason = max(wReason, len(in.reason))
+    }
+    wTime := len(layout)
+    header := fmt.Sprintf("%-*s  %-*s  %9s  %-*s  %-6s  %s", wTime, "START", wTime, "END", "DURATION", wHost, "HOST", "STATE", "REASON")
+    out.WriteString(lipgloss.NewStyle().Bold(true).Render(header) + "\n")
+    rows := m.incidentRows()
+    offset := min(m.incidentOffset, max(len(list)-rows, 0))
+    if len(list) == 0 {
+        out.WriteString(dimStyle.Render("no incidents") + "\n")
+    }
+    for _, in := range list[offset:min(offset+rows, len(list))] {
+        end, dur := in.end.Format(layout), shortDuration(in.duration(now))
+        if in.end.IsZero() {
+            end, dur = "unknown", "-"
+            if monitored[in.key] {
+                end, dur = "ongoing", shortDuration(in.duration(now))
+            }
+        }
+        reason := in.reason
+        if reason == "" {
+            reason = "-"
+        }
+        line := fmt.Sprintf("%-*s  %-*s  %9s  %-*s  %-6s  %s", wTime, in.start.Format(layout), wTime, end,
+            dur, wHost, in.host(), in.state, reason)
+        if end == "ongoing" {
+            line = ongoingStyle.Render(line)
+        }
+        out.WriteString(line + "\n")
+    }
+    out.WriteString("\n" + dimStyle.Render("/ Filter host   H Selected host   1-4 or T Range   ↑/↓ Scroll   Esc Back") + "\n")
+    return out.String()
+}
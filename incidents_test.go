@@ -0,0 +1,92 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestNoteState(t *testing.T) {
+    type step struct {
+        from, to hostState
+        changed  bool
+    }
+    tests := []struct {
+        name  string
+        steps []step
+        open  []bool // per incident, whether it is still open
+    }{
+        {"up", []step{{statePending, stateUp, false}, {stateUp, stateUp, false}}, nil},
+        {"open and close", []step{
+            {statePending, stateUp, false},
+            {stateUp, stateDown, true},
+            {stateDown, stateDown, false},
+            {stateDown, stateDegraded, true},
+        }, []bool{false}},
+        {"down from the first result", []step{{statePending, stateError, false}}, []bool{true}},
+        {"down to error is one outage", []step{
+            {stateUp, stateDown, true},
+            {stateDown, stateError, true},
+        }, []bool{true}},
+        // A restart finds the host still down: its first result leaves
+        // PENDING for DOWN, which continues the recorded outage
+        {"restart from PENDING", []step{
+            {stateUp, stateDown, true},
+            {statePending, stateDown, true},
+            {stateDown, stateUp, true},
+        }, []bool{false}},
+        {"two outages", []step{
+            {stateUp, stateDown, true},
+            {stateDown, stateUp, true},
+            {stateUp, stateError, true},
+        }, []bool{false, true}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var m model
+            start := at("20260101", 0)
+            for k, s := range tt.steps {
+                m.noteState("a", s.from, s.to, s.changed, "timeout", start.Add(time.Duration(k)*time.Minute))
+            }
+            if len(m.incidents) != len(tt.open) {
+                t.Fatalf("%d incidents, want %d: %+v", len(m.incidents), len(tt.open), m.incidents)
+            }
+            for k, in := range m.incidents {
+                if in.end.IsZero() != tt.open[k] {
+                    t.Errorf("incident %d ended %v, want open %v", k, in.end, tt.open[k])
+                }
+            }
+            // Only the last incident can be ongoing
+            k, ok := m.openIncidents["a"]
+            if last := len(tt.open) - 1; ok != (last >= 0 && tt.open[last]) || ok && k != last {
+                t.Errorf("open incidents %v, want incident %d only if it is ongoing", m.openIncidents, last)
+            }
+        })
+    }
+}
+
+func TestIncidentOfEditedHost(t *testing.T) {
+    m := newTestModel(t, "a.example", "b.example")
+    a := idOf(m, "a.example")
+    m, _ = send(m, downResult(m.schedGen, a, 1))
+    if len(m.openIncidents) != 1 {
+        t.Fatalf("open incidents %v, want a.example's", m.openIncidents)
+    }
+
+    // Editing the options gives a.example a new key; the outage under the
+    // old key ends, and the next result starts one under the new key
+    m, _ = send(m, keys("e", " fall=2", "enter", "enter")...)
+    if !m.incidents[0].end.After(m.incidents[0].start) || len(m.openIncidents) != 0 {
+        t.Fatalf("after the edit: incidents %+v, open %v; want the outage ended", m.incidents, m.openIncidents)
+    }
+    a = idOf(m, "a.example")
+    m, _ = send(m, downResult(m.schedGen, a, 2))
+    if _, ok := m.openIncidents["a.example fall=2"]; !ok || len(m.incidents) != 2 {
+        t.Errorf("incidents %+v, open %v; want a new one under the new key", m.incidents, m.openIncidents)
+    }
+
+    // Deleting a host that is down ends its outage too
+    m, _ = send(m, keys("d", "y")...)
+    if idOf(m, "a.example") != -1 || len(m.openIncidents) != 0 || m.incidents[1].end.IsZero() {
+        t.Errorf("after deleting: incidents %+v, open %v; want all ended", m.incidents, m.openIncidents)
+    }
+}
@@ -3,14 +3,16 @@ package main
 import (
     "bufio"
     "context"
+    "errors"
     "fmt"
     "os"
     "os/exec"
+    "regexp"
     "runtime"
+    "slices"
     "sort"
     "strconv"
     "strings"
-    "sync"
     "time"
 
     "net"
@@ -23,8 +25,77 @@ import (
 
 // Host represents a single ping target along with a description.
 type Host struct {
-    Host string
-    Desc string
+    ID     int    // stable identity within this run, assigned by the model
+    Host   string
+    Desc   string
+    Family addrFamily // address family to probe over
+    Probe  probeKind  // how the host is checked, derived from Host
+
+    // HTTP(S) checks: accepted status codes, optional body substring and
+    // regular expression, compiled when the host is parsed, and how many
+    // redirects to follow (0 means the default, -1 none).
+    Expect    statusRange
+    Match     string
+    Regex     *regexp.Regexp
+    Redirects int
+
+    // TLS checks: server name sent via SNI (the target host if empty) and
+    // the number of days before certificate expiry at which the row turns
+    // to the warning colour (0 means the default).
+    SNI      string
+    CertWarn int
+
+    // Count overrides the global number of packets per round (0 means use
+    // the global setting).
+    Count int
+
+    // Interval overrides the global probe interval for this host (0 means
+    // use the global setting). Jitter delays the host's first probe by a
+    // random amount of up to this duration.
+    Interval time.Duration
+    Jitter   time.Duration
+
+    // Fall and Rise override the global number of consecutive failed rounds
+    // before the host is marked down, and of successful rounds before it is
+    // marked up again (0 means use the global setting).
+    Fall int
+    Rise int
+
+    // Warn and Crit override the global warning and critical latency
+    // (0 means use the global setting).
+    Warn time.Duration
+    Crit time.Duration
+
+    // Window overrides the global window of the latency statistics
+    // (0 means use the global setting).
+    Window time.Duration
+}
+
+// hostState is the overall state of a host as shown in the STATUS column.
+// The zero value is statePending, used until a host's first round finished.
+type hostState int
+
+const (
+    statePending  hostState = iota
+    stateUp                 // reachable
+    stateDegraded           // reachable, but with loss, a warning or one family down
+    stateDown               // the host didn't answer or failed its check
+    stateError              // the host couldn't be probed at all, e.g. unknown name
+)
+
+// String returns the label shown in the STATUS column.
+func (s hostState) String() string {
+    switch s {
+    case stateUp:
+        return "UP"
+    case stateDegraded:
+        return "DEGRADED"
+    case stateDown:
+        return "DOWN"
+    case stateError:
+        return "ERROR"
+    }
+    return "PENDING"
 }
 
 // pingResult holds the outcome of pinging a host. A negative reply means the host
@@ -33,23 +104,206 @@ type Host struct {
 // the host responded and the round‑trip time, it records when the status
 // last changed. A zero time indicates the status has never been evaluated.
 type pingResult struct {
+    state      hostState
     status     bool
     reply      float64
     lastChange time.Time
     flashUntil time.Time
+
+    // dual is set for hosts probed over both IPv4 and IPv6. status and reply
+    // then describe IPv4 while status6 and reply6 describe IPv6.
+    dual    bool
+    status6 bool
+    reply6  float64
+
+    // fail classifies why a probe failed and reason explains it briefly for
+    // the REASON column, e.g. "refused" or "status 503". fail6 and reason6
+    // are the IPv6 counterparts.
+    fail    failureKind
+    fail6   failureKind
+    reason  string
+    reason6 string
+
+    // info carries probe specific details for the INFO column, such as the
+    // HTTP status code and timing breakdown.
+    info string
+    // warn flags a reachable host that needs attention anyway, such as a
+    // certificate close to expiry. The row is drawn in the warning colour.
+    warn bool
+    // critical flags a host whose reply time reached the critical latency,
+    // see rateLatency. It stays DEGRADED but shows as CRITICAL.
+    critical bool
+
+    // suspect is set while the latest rounds disagree with state but haven't
+    // reached the host's fall or rise threshold yet; streak counts them.
+    suspect bool
+    streak  int
+    // changes holds the times of the host's state changes within the flap
+    // window; flapping is set while they come too often.
+    changes  []time.Time
+    flapping bool
+
+    // stats summarises all probes of the round; reply is then their average.
+    stats roundStats
+    // uptime is the host's uptime over the uptimeWindows, see uptime.go.
+    uptime uptimeStats
+    // round is the number of the probe round that produced this result.
+    round uint64
 }
 
-// pingResultsMsg is sent to the update loop containing the results for all
-// hosts. The order of the slice corresponds to the order of the hosts slice.
-type pingResultsMsg []pingResult
+// evaluate derives the host state from a finished round and returns the
+// result with state set. A host is up when it answered every probe, and
+// degraded when it answered only some of them, carries a warning, or is
+// dual‑stack with only one family answering. Failures caused by our side,
+// such as a name that doesn't resolve, make the host ERROR rather than DOWN.
+func (r pingResult) evaluate() pingResult {
+    family := func(up bool, fail failureKind) hostState {
+        switch {
+        case up:
+            return stateUp
+        case fail.isError():
+            return stateError
+        }
+        return stateDown
+    }
+    r.state = family(r.status, r.fail)
+    if r.dual {
+        s6 := family(r.status6, r.fail6)
+        switch {
+        case r.state == stateUp && s6 == stateUp:
+        case r.state == stateUp || s6 == stateUp:
+            r.state = stateDegraded
+        case r.state == stateError && s6 == stateError:
+            r.state = stateError
+        default:
+            r.state = stateDown
+        }
+    }
+    if r.state != stateUp {
+        return r
+    }
+    switch {
+    case r.stats.recv < r.stats.sent:
+        r.state, r.reason = stateDegraded, "packet loss"
+    case r.warn:
+        r.state, r.reason = stateDegraded, "warning"
+    default:
+        r.reason, r.reason6 = "", ""
+    }
+    return r
+}
 
-// tickMsg signals it's time to perform the next round of pings.
-type tickMsg time.Time
+// up reports whether the host counts as reachable, i.e. is up or degraded.
+func (r pingResult) up() bool {
+    return r.state == stateUp || r.state == stateDegraded
+}
 
-// Available sort options for the host list. The first element corresponds
-// to alphabetical sorting by host name; the second sorts by resolved IP
-// address.
-var sortChoices = []string{"name", "ip", "status", "reply", "age"}
+// statusText renders the STATUS column. Dual‑stack hosts that are only
+// partly reachable show the state of each family, e.g. "4:UP 6:DOWN".
+func (r pingResult) statusText() string {
+    if r.flapping {
+        return "FLAPPING"
+    }
+    if r.suspect {
+        return "SUSPECT"
+    }
+    if r.critical && r.up() {
+        return "CRITICAL"
+    }
+    if !r.dual || r.state == statePending || (r.status && r.status6) {
+        return r.state.String()
+    }
+    word := func(up bool, fail failureKind) string {
+        switch {
+        case up:
+            return "UP"
+        case fail.isError():
+            return "ERROR"
+        }
+        return "DOWN"
+    }
+    return "4:" + word(r.status, r.fail) + " 6:" + word(r.status6, r.fail6)
+}
+
+// replyText renders the REPLY column. Dual‑stack hosts show the IPv4 and
+// IPv6 values separated by a slash.
+func (r pingResult) replyText() string {
+    ms := func(up bool, reply float64) string {
+        if up && reply >= 0 {
+            return fmt.Sprintf("%.1f", reply)
+        }
+        return "-"
+    }
+    if r.state == statePending {
+        return "-"
+    }
+    if r.dual {
+        return ms(r.status, r.reply) + "/" + ms(r.status6, r.reply6)
+    }
+    return ms(r.status, r.reply)
+}
+
+// reasonText renders the REASON column: why the host isn't plainly up.
+// Dual‑stack hosts prefix each family's reason. Flapping hosts lead with
+// the number of recent state changes.
+func (r pingResult) reasonText() string {
+    if r.flapping {
+        text := r.flapText()
+        if reason := r.reasonOnly(); reason != "" {
+            text += ", " + reason
+        }
+        return text
+    }
+    return r.reasonOnly()
+}
+
+// reasonOnly returns the probe's own reason for the REASON column.
+func (r pingResult) reasonOnly() string {
+    if !r.dual || r.reason6 == "" || r.state == stateDegraded && r.status && r.status6 {
+        return r.reason
+    }
+    if r.reason == "" {
+        return "6:" + r.reason6
+    }
+    return "4:" + r.reason + " 6:" + r.reason6
+}
+
+// stateRank orders host states for sorting by status: healthy hosts first,
+// hosts still waiting for their first result last.
+var stateRank = map[hostState]int{
+    stateUp:       0,
+    stateDegraded: 1,
+    stateDown:     3,
+    stateError:    4,
+    statePending:  5,
+}
+
+// rank returns the result's place when sorting by status. Critically slow
+// hosts come between the other degraded ones and those that are down.
+func (r pingResult) rank() int {
+    if r.critical && r.up() {
+        return 2
+    }
+    return stateRank[r.state]
+}
+
+// describe explains the result in a sentence naming the host, such as
+// "DNS failed for gnu.org" or "gnu.org is DOWN: refused".
+func (r pingResult) describe(host string) string {
+    if r.fail == failDNS && (!r.dual || r.fail6 == failDNS) {
+        return r.reason + " for " + host
+    }
+    text := host + " is " + r.state.String()
+    if reason := r.reasonText(); reason != "" {
+        text += ": " + reason
+    }
+    return text
+}
+
+// Available sort options for the host list: by host name, resolved IP
+// address, state, reply time and age of the last change, followed by the
+// keys of statColumns.
+var sortChoices = []string{"name", "ip", "status", "reply", "age", "p50", "p90", "p95", "p99", "jitter", "stddev", "up1h", "up24h", "up7d", "up30d"}
 
 // modelMode enumerates the various high‑level states the TUI can be in.
 type modelMode int
@@ -60,12 +314,19 @@ const (
     modeEdit
     modeConfirmDelete
     modeOptions
+    modeDetail
+    modeIncidents
+    modeExport
 )
 
 // model encapsulates all state for the bubbletea program.
 type model struct {
     hosts   []Host      // loaded hosts, sorted by hostname
-    results []pingResult // current status for each host
+    results map[int]pingResult // current status keyed by host ID
+    history map[int]*history   // recent rounds keyed by host ID, see history.go
+    uptime  map[int]*uptime    // rounds counted for the uptime columns keyed by host ID
+    store   *store             // on-disk record of rounds and changes, nil if unavailable
+    replay  *replay            // set when replaying a recording, see replay.go
     cursor  int          // selected row in table
     width   int          // width of the terminal
     height  int          // height of the terminal
@@ -80,20 +341,151 @@ type model struct {
     message    string      // temporary message displayed at bottom of table
 
     interval time.Duration // ping interval
+    count    int           // packets per host and round
+    workers  int           // maximum hosts probed concurrently
+    pps      int           // global packets per second budget, 0 for unlimited
+    fall     int           // failed rounds before a host is marked down
+    rise     int           // successful rounds before a host is marked up
+    latWarn  time.Duration // reply time at which a host is degraded, 0 for off
+    latCrit  time.Duration // reply time at which a host is critically slow, 0 for off
     quitting bool          // indicates program should quit
 
+    // Latency statistics: the window they cover and the keys of the
+    // statColumns shown in the table.
+    statWindow time.Duration
+    statCols   []string
+
+    // rounds holds recent round durations for the status line.
+    rounds roundTimes
+
+    // recordFails counts the rounds the store failed to write since the
+    // last one it wrote; recordReported is when that was last reported.
+    recordFails    int
+    recordReported time.Time
+
+    // schedGen is the current schedule generation, see sched.go.
+    schedGen int
+    // nextID is the last host ID handed out; round the last probe round
+    // number handed out. Both only ever grow.
+    nextID int
+    round  uint64
+
+    // Detail screen: the host shown, the index into chartWindows and the
+    // scrubbing cursor as a column offset from the latest, see detail.go.
+    detailID     int
+    detailWindow int
+    detailOffset int
+
+    // Incident log, oldest first, with the index of each host's ongoing
+    // incident by host spec, and the incidents screen's host filter, index
+    // into incidentRanges and scroll offset, see incidents.go.
+    incidents      []incident
+    openIncidents  map[string]int
+    incidentFilter textinput.Model
+    incidentRange  int
+    incidentOffset int
+
+    // Fields of the export dialog, see export.go
+    exportHost  textinput.Model
+    exportFrom  textinput.Model
+    exportTo    textinput.Model
+    exportFile  textinput.Model
+    exportFocus int
+    exporting   string // file an export is being written to, "" if none
+
     // Sorting preference: "name" or "ip". Determines how hosts are ordered.
     sortBy string
 
     // Fields used for the options dialog
     optInterval textinput.Model
+    optCount    textinput.Model
+    optWorkers  textinput.Model
+    optPPS      textinput.Model
+    optFall     textinput.Model
+    optRise     textinput.Model
+    optWarn     textinput.Model
+    optCrit     textinput.Model
+    optWindow   textinput.Model
+    optColumns  textinput.Model
     // In options mode we present a small list of sort choices rather than a text input.
     optSortIndex int  // index into optSortChoices
-    optFocus     int  // 0 for interval input, 1 for sort selection
+    optFocus     int  // index into optInputs, or len(optInputs) for sort selection
+}
+
+// optInputs returns the text inputs of the options dialog in focus order.
+func (m *model) optInputs() []*textinput.Model {
+    return []*textinput.Model{&m.optInterval, &m.optCount, &m.optWorkers, &m.optPPS, &m.optFall, &m.optRise, &m.optWarn, &m.optCrit, &m.optWindow, &m.optColumns}
+}
+
+// assignIDs gives every host in hosts a fresh ID. IDs are never reused, so a
+// result for a host that has since been removed or replaced can't attach to
+// another row.
+func (m *model) assignIDs(hosts []Host) {
+    for i := range hosts {
+        m.nextID++
+        hosts[i].ID = m.nextID
+    }
+}
+
+// reuseIDs gives each host in hosts the ID of an identical host already in
+// the list, so its results and running timer carry over, and a fresh ID to
+// every other host. Hosts are identical when their target and options match;
+// the description doesn't matter. The hosts that got fresh IDs are returned.
+func (m *model) reuseIDs(hosts []Host) []Host {
+    existing := make(map[string][]int)
+    for _, h := range m.hosts {
+        existing[h.spec()] = append(existing[h.spec()], h.ID)
+    }
+    var fresh []Host
+    for i := range hosts {
+        key := hosts[i].spec()
+        if ids := existing[key]; len(ids) > 0 {
+            hosts[i].ID = ids[0]
+            existing[key] = ids[1:]
+            continue
+        }
+        m.nextID++
+        hosts[i].ID = m.nextID
+        fresh = append(fresh, hosts[i])
+    }
+    return fresh
+}
+
+// pruneResults drops the results, history and uptime counts of hosts that
+// are no longer in the list.
+func (m *model) pruneResults() {
+    keep := make(map[int]bool, len(m.hosts))
+    for _, h := range m.hosts {
+        keep[h.ID] = true
+    }
+    for id := range m.results {
+        if !keep[id] {
+            m.forget(id)
+        }
+    }
+    for id := range m.history {
+        if !keep[id] {
+            m.forget(id)
+        }
+    }
+    for id := range m.uptime {
+        if !keep[id] {
+            m.forget(id)
+        }
+    }
+}
+
+// forget drops the result, history and uptime counts of the host with the
+// given ID.
+func (m *model) forget(id int) {
+    delete(m.results, id)
+    delete(m.history, id)
+    delete(m.uptime, id)
 }
 
 // loadHostsFromFile reads hosts from hosts.txt. Each line should have the form
-// "host,description". Blank lines are ignored. The returned slice is sorted
+// "host,description", where the host column may carry options as described
+// by parseHostSpec. Blank lines are ignored. The returned slice is sorted
 // alphabetically by host.
 func loadHostsFromFile(path string) ([]Host, error) {
     file, err := os.Open(path)
@@ -103,7 +495,9 @@ func loadHostsFromFile(path string) ([]Host, error) {
     defer file.Close()
     var hosts []Host
     scanner := bufio.NewScanner(file)
+    lineNo := 0
     for scanner.Scan() {
+        lineNo++
         line := strings.TrimSpace(scanner.Text())
         if line == "" {
             continue
@@ -115,7 +509,12 @@ func loadHostsFromFile(path string) ([]Host, error) {
             desc = strings.TrimSpace(parts[1])
         }
         if host != "" {
-            hosts = append(hosts, Host{Host: host, Desc: desc})
+            h, err := parseHostSpec(host)
+            if err != nil {
+                return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+            }
+            h.Desc = desc
+            hosts = append(hosts, h)
         }
     }
     if err := scanner.Err(); err != nil {
@@ -140,7 +539,7 @@ func saveHostsToFile(path string, hosts []Host) error {
                 return err
             }
         }
-        line := h.Host
+        line := h.spec()
         if h.Desc != "" {
             line += "," + h.Desc
         }
@@ -162,10 +561,25 @@ func (m *model) sortHosts() {
     for i := 0; i < n; i++ {
         idx[i] = i
     }
+    // Statistics are looked up once per host rather than per comparison.
+    // Hosts without data go to the bottom.
+    var stat []float64
+    if col, ok := statColumnFor(m.sortBy); ok {
+        stat = make([]float64, n)
+        for i, h := range m.hosts {
+            v, ok := col.value(m.results[h.ID], m.latencyStatsFor(h))
+            if !ok {
+                v = 1e9
+            }
+            stat[i] = v
+        }
+    }
     // Sort the indices according to the chosen criterion. Use stable sort
     // semantics so that equal elements retain relative order.
     sort.SliceStable(idx, func(a, b int) bool {
         i, j := idx[a], idx[b]
+        // Hosts without a result yet compare as zero results
+        resA, resB := m.results[m.hosts[i].ID], m.results[m.hosts[j].ID]
         switch m.sortBy {
         case "ip":
             ipA := m.hosts[i].Host
@@ -178,48 +592,42 @@ func (m *model) sortHosts() {
             }
             return ipA < ipB
         case "status":
-            // Show reachable hosts first; if both same, fallback to name
-            var statusA, statusB bool
-            if i < len(m.results) {
-                statusA = m.results[i].status
-            }
-            if j < len(m.results) {
-                statusB = m.results[j].status
-            }
-            if statusA != statusB {
-                return statusA && !statusB
+            // Show healthy hosts first; if both same, fallback to name
+            rankA, rankB := resA.rank(), resB.rank()
+            if rankA != rankB {
+                return rankA < rankB
             }
             return strings.ToLower(m.hosts[i].Host) < strings.ToLower(m.hosts[j].Host)
         case "reply":
             // Sort by reply time ascending; unreachable (reply <0) go to bottom
             var rA, rB float64 = 1e9, 1e9
-            if i < len(m.results) {
-                if m.results[i].status {
-                    rA = m.results[i].reply
-                }
+            if resA.status {
+                rA = resA.reply
             }
-            if j < len(m.results) {
-                if m.results[j].status {
-                    rB = m.results[j].reply
-                }
+            if resB.status {
+                rB = resB.reply
             }
             if rA != rB {
                 return rA < rB
             }
             return strings.ToLower(m.hosts[i].Host) < strings.ToLower(m.hosts[j].Host)
+        case "p50", "p90", "p95", "p99", "jitter", "stddev", "up1h", "up24h", "up7d", "up30d":
+            // Sort by the statistic ascending, which puts the lowest uptime
+            // first
+            vA, vB := stat[i], stat[j]
+            if vA != vB {
+                return vA < vB
+            }
+            return strings.ToLower(m.hosts[i].Host) < strings.ToLower(m.hosts[j].Host)
         case "age":
             // Sort by age descending (largest age first)
             ageA := 0.0
             ageB := 0.0
-            if i < len(m.results) {
-                if !m.results[i].lastChange.IsZero() {
-                    ageA = time.Since(m.results[i].lastChange).Seconds()
-                }
+            if !resA.lastChange.IsZero() {
+                ageA = m.now().Sub(resA.lastChange).Seconds()
             }
-            if j < len(m.results) {
-                if !m.results[j].lastChange.IsZero() {
-                    ageB = time.Since(m.results[j].lastChange).Seconds()
-                }
+            if !resB.lastChange.IsZero() {
+                ageB = m.now().Sub(resB.lastChange).Seconds()
             }
             if ageA != ageB {
                 return ageA > ageB
@@ -230,108 +638,159 @@ func (m *model) sortHosts() {
             return strings.ToLower(m.hosts[i].Host) < strings.ToLower(m.hosts[j].Host)
         }
     })
-    // Apply the sorted order to hosts and results
+    // Apply the sorted order to hosts. Results are keyed by host ID and
+    // need no reordering.
     newHosts := make([]Host, n)
-    newResults := make([]pingResult, len(m.results))
     for k, original := range idx {
         newHosts[k] = m.hosts[original]
-        if original < len(m.results) {
-            newResults[k] = m.results[original]
-        }
     }
     m.hosts = newHosts
-    m.results = newResults
 }
 
-// pingHost attempts to ping a host once. It returns whether the host is up
-// and, if up, the round‑trip time in milliseconds. For non‑Windows systems it
-// relies on the system ping command with a count of 1. A context with timeout
-// is used to enforce an upper bound on execution time. On any error or
-// timeout, the host is considered down and the reply time is set to -1.
-func pingHost(host string) (bool, float64) {
+// pingAddr attempts to ping a host once. It returns whether the host is up
+// and, if up, the round‑trip time in milliseconds. The built‑in ICMP engine
+// is used whenever an ICMP socket can be opened; otherwise the system ping
+// command is run instead.
+func pingAddr(host string, fam addrFamily) pingResult {
+    if res, err := pingHostNative(host, fam); err == nil {
+        return res
+    }
+    res, _ := pingHostExec(host, fam, 1)
+    return res
+}
+
+// execGap spaces the echo requests of one ping run on Linux, the smallest
+// gap ping grants users other than root.
+const execGap = 200 * time.Millisecond
+
+// pingHostExec runs the system ping command for count echo requests and
+// builds the round from every reply and error the parser for the platform's
+// ping implementation finds in its output, see replyRound. A context with
+// timeout bounds the execution time. If ping couldn't be run at all, the
+// result says so.
+func pingHostExec(host string, fam addrFamily, count int) (pingResult, []float64) {
+    n := strconv.Itoa(count)
+    // wait is how long the run may take: the gaps between the requests plus
+    // the time to wait for the last reply.
     var args []string
-    if runtime.GOOS == "windows" {
-        // On Windows: -n <count>, -w <timeout_ms>
-        args = []string{"-n", "1", "-w", "1000", host}
-    } else {
-        // On Unix/Mac: -c <count>. We'll rely on the context timeout to kill
-        // the process if it takes too long.
-        args = []string{"-c", "1", host}
+    var wait time.Duration
+    switch runtime.GOOS {
+    case "windows":
+        // On Windows: -n <count>, -w <timeout_ms>. Requests go out a second
+        // apart.
+        args = []string{"-n", n, "-w", "1000"}
+        wait = time.Duration(count-1)*time.Second + icmpTimeout
+    case "linux":
+        // iputils and busybox: -c <count>, -i <gap>, -w <deadline>. Users
+        // other than root may not go below a 200 ms gap. The deadline ends
+        // the run cleanly with its output complete.
+        wait = time.Duration(count-1)*execGap + icmpTimeout
+        secs := strconv.Itoa(int((wait + time.Second - 1) / time.Second))
+        args = []string{"-c", n, "-i", "0.2", "-w", secs}
+    default:
+        // On the BSDs and macOS: -c <count>, a second apart. We'll rely on
+        // the context timeout to kill the process if it takes too long.
+        args = []string{"-c", n}
+        wait = time.Duration(count-1)*time.Second + icmpTimeout
     }
-    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-    defer cancel()
-    out, err := exec.CommandContext(ctx, "ping", args...).CombinedOutput()
-    if err != nil && ctx.Err() == context.DeadlineExceeded {
-        return false, -1
-    }
-    // Determine success by looking for TTL in output. Different platforms
-    // capitalise TTL differently.
-    outStr := string(out)
-    if strings.Contains(strings.ToLower(outStr), "ttl=") {
-        // Attempt to extract the time using a simple substring search.
-        // The output usually contains "time=XX ms" or "time<XX ms".
-        // We'll search for "time" followed by '=' or '<', then grab the
-        // number until the next space.
-        idx := strings.Index(outStr, "time")
-        if idx != -1 {
-            // Move past 'time' and any '=' or '<' characters
-            j := idx + len("time")
-            for j < len(outStr) && (outStr[j] == '=' || outStr[j] == '<' || outStr[j] == ' ') {
-                j++
-            }
-            // Extract digits and decimal point
-            start := j
-            for j < len(outStr) && (outStr[j] == '.' || (outStr[j] >= '0' && outStr[j] <= '9')) {
-                j++
-            }
-            if start < j {
-                valStr := outStr[start:j]
-                if v, err := strconv.ParseFloat(valStr, 64); err == nil {
-                    return true, v
-                }
-            }
+    bin := "ping"
+    switch fam {
+    case family4:
+        args = append(args, "-4")
+    case family6:
+        // macOS ships a separate ping6 binary and rejects -6.
+        if runtime.GOOS == "darwin" {
+            bin = "ping6"
+        } else {
+            args = append(args, "-6")
         }
-        // Host is up but we couldn't parse the time
-        return true, -1
     }
-    return false, -1
+    args = append(args, host)
+    ctx, cancel := context.WithTimeout(context.Background(), wait+time.Second)
+    defer cancel()
+    out, err := exec.CommandContext(ctx, bin, args...).CombinedOutput()
+    if errors.Is(err, exec.ErrNotFound) {
+        return failed(failExec, bin+" not found", ""), nil
+    }
+    replies := parsePingOutput(runtime.GOOS, string(out))
+    if len(replies) == 0 && err != nil && ctx.Err() == nil && !errors.As(err, new(*exec.ExitError)) {
+        // ping couldn't be run at all, e.g. it isn't executable.
+        return failed(failExec, "", ""), nil
+    }
+    // Output printed before a timeout killed ping still counts.
+    return replyRound(replies, count)
 }
 
-// tickCmd returns a command that waits for m.interval before sending a tickMsg.
-func (m model) tickCmd() tea.Cmd {
-    return tea.Tick(m.interval, func(t time.Time) tea.Msg {
-        return tickMsg(t)
-    })
+// replyRound turns the replies parsed from the output of a ping run with
+// count echo requests into the result of a round, as probeRound does for
+// probes sent one by one. Each sequence number counts once: duplicates are
+// dropped and an echo reply wins over an error reported for the same
+// request. Without any echo reply, the first error explains the failure;
+// requests that drew neither timed out. INFO shows the TTL of the last
+// reply.
+func replyRound(replies []pingReply, count int) (pingResult, []float64) {
+    bySeq := make(map[int]int)
+    var uniq []pingReply
+    for _, r := range replies {
+        k, seen := bySeq[r.seq]
+        switch {
+        case !seen:
+            bySeq[r.seq] = len(uniq)
+            uniq = append(uniq, r)
+        case uniq[k].fail != failNone && r.fail == failNone:
+            uniq[k] = r
+        }
+    }
+    res := failed(failTimeout, "", "")
+    var rtts []float64
+    recv := 0
+    for _, r := range uniq {
+        if r.fail != failNone {
+            if recv == 0 && res.fail == failTimeout {
+                res = failed(r.fail, r.reason, "")
+            }
+            continue
+        }
+        if recv == count {
+            break
+        }
+        recv++
+        res = pingResult{status: true, reply: -1, info: ttlInfo(r.ttl)}
+        if r.rtt >= 0 {
+            rtts = append(rtts, r.rtt)
+        }
+    }
+    res.stats = statsOf(rtts, count, recv)
+    if len(rtts) > 0 {
+        res.reply = res.stats.avg
+    }
+    return res, rtts
 }
 
-// pingAllCmd returns a command that concurrently pings all hosts and returns
-// the results in a pingResultsMsg.
-func pingAllCmd(hosts []Host) tea.Cmd {
-    return func() tea.Msg {
-        results := make([]pingResult, len(hosts))
-        var wg sync.WaitGroup
-        for i, h := range hosts {
-            wg.Add(1)
-            go func(i int, host string) {
-                defer wg.Done()
-                up, ms := pingHost(host)
-                results[i] = pingResult{status: up, reply: ms}
-            }(i, h.Host)
-        }
-        wg.Wait()
-        return pingResultsMsg(results)
+// ttlInfo describes the TTL or hop limit of a reply for the INFO column, if
+// it is known.
+func ttlInfo(ttl int) string {
+    if ttl <= 0 {
+        return ""
     }
+    return "ttl " + strconv.Itoa(ttl)
 }
 
-// Init implements tea.Model. It sets up the program by triggering an initial
-// ping and requesting a window size. It also starts the periodic tick.
+// loadingHistory is the message shown while the recorded history is read.
+const loadingHistory = "Loading recorded history…"
+
+// Init implements tea.Model. It sets up the program by starting the per‑host
+// timers, which probe every host right away.
 func (m model) Init() tea.Cmd {
-    // Start ticking and perform an initial ping. The alt screen is enabled
-    // via tea.NewProgram in main().
-    return tea.Batch(
-        m.tickCmd(),
-        pingAllCmd(m.hosts),
-    )
+    // The alt screen is enabled via tea.NewProgram in main(). A replay
+    // probes nothing and runs its clock instead.
+    if m.replay != nil {
+        return replayTickCmd()
+    }
+    if m.store != nil {
+        return tea.Batch(m.scheduleCmd(), m.loadHistoryCmd())
+    }
+    return m.scheduleCmd()
 }
 
 // setMessage assigns a temporary message to be displayed at the bottom of
@@ -341,6 +800,70 @@ func (m *model) setMessage(msg string) {
     m.message = msg
 }
 
+// applyResult records a new probe result for the host with the given ID and
+// tracks the last change time. A state change highlights the row and rings
+// the bell, except for a host's first result, which only leaves PENDING.
+// Flips between reachable and unreachable are subject to the host's
+// thresholds, see state.go. Results from a round older than the one already recorded are
+// discarded; they were overtaken by a newer round after a reschedule.
+func (m *model) applyResult(id int, res pingResult) {
+    now := time.Now()
+    if m.results == nil {
+        m.results = make(map[int]pingResult)
+    }
+    i := m.hostIndex(id)
+    prev := m.results[id]
+    if i < 0 || res.round < prev.round {
+        return
+    }
+    // Keep the round in the host's history
+    m.historyFor(m.hosts[i]).add(sampleOf(res, now))
+    // Rate the reply time, then hold back flips that haven't reached the
+    // host's threshold yet
+    newRes := m.settle(m.hosts[i], prev, m.rateLatency(m.hosts[i], res))
+    newRes.uptime = m.countUptime(id, newRes, now)
+    newRes.lastChange = prev.lastChange
+    changed := prev.state != statePending && prev.state != newRes.state
+    newRes.trackFlapping(prev, changed, now)
+    // Announce flapping once instead of every change
+    if newRes.flapping && !prev.flapping {
+        m.setMessage(m.hosts[i].Host + " is flapping (" + newRes.flapText() + ")")
+    } else if prev.flapping && !newRes.flapping {
+        m.setMessage(m.hosts[i].Host + " stopped flapping")
+    }
+    // If this is the first time we've evaluated this host, record now as the
+    // last change time.
+    if prev.state == statePending {
+        newRes.lastChange = now
+    } else if changed && newRes.flapping {
+        // Flapping hosts change quietly
+        newRes.lastChange = now
+    } else if changed {
+        // The state changed; update last change time
+        newRes.lastChange = now
+        // Highlight the row for a short period and play a beep
+        newRes.flashUntil = now.Add(2 * time.Second)
+        // Print a bell character to trigger terminal beep
+        fmt.Print("\a")
+        // Say what happened in the message line
+        m.setMessage(newRes.describe(m.hosts[i].Host))
+    } else if newRes.critical && !prev.critical && prev.state != statePending && !newRes.flapping {
+        // Reaching the critical latency alerts like a state change, even
+        // if the host was DEGRADED already
+        newRes.flashUntil = now.Add(2 * time.Second)
+        fmt.Print("\a")
+        m.setMessage(newRes.describe(m.hosts[i].Host))
+    } else {
+        // carry over existing flash window if still active
+        if prev.flashUntil.After(now) {
+            newRes.flashUntil = prev.flashUntil
+        }
+    }
+    m.record(m.hosts[i], prev, newRes, changed, now)
+    m.noteState(m.hosts[i].spec(), prev.state, newRes.state, changed, newRes.reasonOnly(), now)
+    m.results[id] = newRes
+}
+
 // Update implements tea.Model. It handles all incoming messages and updates
 // the model accordingly.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -350,43 +873,53 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         m.width = msg.Width
         m.height = msg.Height
         return m, nil
-    case tickMsg:
-        // Schedule a ping
-        return m, pingAllCmd(m.hosts)
-    case pingResultsMsg:
-        // Update statuses and track last change times. Schedule the next tick.
-        now := time.Now()
-        // Ensure results slice exists and has correct length
-        if m.results == nil || len(m.results) != len(msg) {
-            m.results = make([]pingResult, len(msg))
-        }
-        for i, res := range msg {
-            prev := m.results[i]
-            newRes := pingResult{status: res.status, reply: res.reply, lastChange: prev.lastChange}
-            // If this is the first time we've evaluated this host, record now as the
-            // last change time.
-            if newRes.lastChange.IsZero() {
-                newRes.lastChange = now
-            }
-            // If status flipped, update last change time
-            if prev.status != res.status {
-                newRes.lastChange = now
-                // Highlight the row for a short period and play a beep
-                newRes.flashUntil = now.Add(2 * time.Second)
-                // Print a bell character to trigger terminal beep
-                fmt.Print("\a")
-            } else {
-                // carry over existing flash window if still active
-                if prev.flashUntil.After(now) {
-                    newRes.flashUntil = prev.flashUntil
-                }
-            }
-            m.results[i] = newRes
+    case replayTickMsg:
+        m.advanceReplay(time.Time(msg))
+        return m, replayTickCmd()
+    case exportDoneMsg:
+        m.exportDone(msg)
+        return m, nil
+    case historyLoadedMsg:
+        m.mergeHistory(msg.stored)
+        if msg.err != nil {
+            m.setMessage("Failed to load history: " + msg.err.Error())
+        } else if m.message == loadingHistory {
+            m.setMessage("")
+        }
+        return m, nil
+    case hostTickMsg:
+        // Timers from an older schedule generation are dropped; the host list
+        // or interval has changed since and fresh timers are running.
+        if msg.gen != m.schedGen {
+            return m, nil
+        }
+        i := m.hostIndex(msg.id)
+        if i < 0 {
+            return m, nil
+        }
+        m.round++
+        return m, probeCmd(msg.gen, m.round, m.hosts[i], m.count)
+    case hostResultMsg:
+        // Apply the result if the host still exists. A host that was edited
+        // or removed while the round was in flight has a new ID or none, so
+        // its stale result is dropped here. Only results from the current
+        // generation arm the host's next tick.
+        i := m.hostIndex(msg.id)
+        if i < 0 {
+            return m, nil
+        }
+        m.applyResult(msg.id, msg.res)
+        m.rounds.add(msg.took)
+        if msg.gen != m.schedGen {
+            return m, nil
         }
-        return m, m.tickCmd()
+        return m, hostTickCmd(msg.gen, msg.id, m.intervalFor(m.hosts[i]))
     case tea.KeyMsg:
         // Global key handling depends on mode
         if m.mode == modeList {
+            if m.replay != nil && m.updateReplayKey(msg.String()) {
+                return m, nil
+            }
             switch msg.String() {
             case "ctrl+c", "q", "Q":
                 m.quitting = true
@@ -401,6 +934,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                     m.cursor++
                 }
                 return m, nil
+            case "enter":
+                // Open the chart for the selected host
+                m.openDetail()
+                return m, nil
+            case "i", "I":
+                // Browse past and ongoing outages
+                m.openIncidentsView()
+                return m, nil
+            case "x", "X":
+                // Export status and history to a file
+                m.openExport()
+                return m, nil
             case "a", "A":
                 // Add new host
                 m.mode = modeAdd
@@ -418,7 +963,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 m.mode = modeEdit
                 m.editIndex = m.cursor
                 m.inputHost = textinput.New()
-                m.inputHost.SetValue(m.hosts[m.editIndex].Host)
+                m.inputHost.SetValue(m.hosts[m.editIndex].spec())
                 m.inputHost.Focus()
                 m.inputDesc = textinput.New()
                 m.inputDesc.SetValue(m.hosts[m.editIndex].Desc)
@@ -441,15 +986,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             case "r", "R":
                 // Reload hosts from file
                 if h, err := loadHostsFromFile("hosts.txt"); err == nil {
+                    // Unchanged hosts keep their IDs and with them their
+                    // history and timers; only new or changed ones start over
+                    fresh := m.reuseIDs(h)
                     m.hosts = h
-                    // Reallocate results slice
-                    m.results = make([]pingResult, len(m.hosts))
+                    m.pruneResults()
+                    m.closeUnlisted(time.Now())
                     // Reset cursor
                     m.cursor = 0
                     m.setMessage("Hosts reloaded")
                     // Sort according to current preference
                     m.sortHosts()
-                    return m, pingAllCmd(m.hosts)
+                    cmds := make([]tea.Cmd, 0, len(fresh))
+                    for _, fh := range fresh {
+                        cmds = append(cmds, hostTickCmd(m.schedGen, fh.ID, 0))
+                    }
+                    return m, tea.Batch(cmds...)
+                } else {
+                    m.setMessage("Failed to reload: " + err.Error())
                 }
                 return m, nil
             case "o", "O":
@@ -460,6 +1014,33 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 m.optInterval.Placeholder = "Interval (0.5–5 s)"
                 m.optInterval.SetValue(fmt.Sprintf("%.1f", m.interval.Seconds()))
                 m.optInterval.Focus()
+                m.optCount = textinput.New()
+                m.optCount.Placeholder = fmt.Sprintf("Packets per round (1–%d)", maxCount)
+                m.optCount.SetValue(strconv.Itoa(m.count))
+                m.optWorkers = textinput.New()
+                m.optWorkers.Placeholder = fmt.Sprintf("Concurrent hosts (1–%d)", maxWorkers)
+                m.optWorkers.SetValue(strconv.Itoa(m.workers))
+                m.optPPS = textinput.New()
+                m.optPPS.Placeholder = "Packets per second (0 = unlimited)"
+                m.optPPS.SetValue(strconv.Itoa(m.pps))
+                m.optFall = textinput.New()
+                m.optFall.Placeholder = fmt.Sprintf("Failed rounds before DOWN (1–%d)", maxThreshold)
+                m.optFall.SetValue(strconv.Itoa(m.fall))
+                m.optRise = textinput.New()
+                m.optRise.Placeholder = fmt.Sprintf("Successful rounds before UP (1–%d)", maxThreshold)
+                m.optRise.SetValue(strconv.Itoa(m.rise))
+                m.optWarn = textinput.New()
+                m.optWarn.Placeholder = "Warning latency in ms (0 = off)"
+                m.optWarn.SetValue(strconv.FormatInt(m.latWarn.Milliseconds(), 10))
+                m.optCrit = textinput.New()
+                m.optCrit.Placeholder = "Critical latency in ms (0 = off)"
+                m.optCrit.SetValue(strconv.FormatInt(m.latCrit.Milliseconds(), 10))
+                m.optWindow = textinput.New()
+                m.optWindow.Placeholder = "Window of the latency statistics, e.g. 5m"
+                m.optWindow.SetValue(m.statWindow.String())
+                m.optColumns = textinput.New()
+                m.optColumns.Placeholder = "Extra columns: p50 p90 p95 p99 jitter stddev up1h up24h up7d up30d"
+                m.optColumns.SetValue(strings.Join(m.statCols, " "))
                 // Determine current sort index
                 m.optSortIndex = 0
                 for i, choice := range sortChoices {
@@ -515,31 +1096,59 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                         m.setMessage("Host cannot be empty")
                         return m, nil
                     }
+                    newHost, err := parseHostSpec(hostVal)
+                    if err != nil {
+                        m.setMessage("Invalid host: " + err.Error())
+                        return m, nil
+                    }
+                    newHost.Desc = descVal
+                    // An edit that only touches the description keeps the
+                    // host's identity and history. Any other added or edited
+                    // host gets a new identity, so results still in flight
+                    // for the old entry are dropped and only its own history
+                    // starts over.
+                    changed := true
+                    if m.mode == modeEdit && m.editIndex >= 0 && m.editIndex < len(m.hosts) {
+                        old := m.hosts[m.editIndex]
+                        if old.spec() == newHost.spec() {
+                            newHost.ID = old.ID
+                            changed = false
+                        } else {
+                            m.forget(old.ID)
+                        }
+                    }
+                    if changed {
+                        m.nextID++
+                        newHost.ID = m.nextID
+                    }
                     if m.mode == modeAdd {
                         // Append new host
-                        m.hosts = append(m.hosts, Host{Host: hostVal, Desc: descVal})
+                        m.hosts = append(m.hosts, newHost)
                     } else if m.mode == modeEdit {
                         // Update existing host
                         if m.editIndex >= 0 && m.editIndex < len(m.hosts) {
-                            m.hosts[m.editIndex] = Host{Host: hostVal, Desc: descVal}
+                            m.hosts[m.editIndex] = newHost
                         }
                     }
                     // Sort hosts and reposition cursor to the edited/added host
                     sort.Slice(m.hosts, func(i, j int) bool { return strings.ToLower(m.hosts[i].Host) < strings.ToLower(m.hosts[j].Host) })
-                    // Rebuild results slice
-                    m.results = make([]pingResult, len(m.hosts))
-                    // find index of hostVal
+                    // find index of the added/edited host
                     m.cursor = 0
                     for i, h := range m.hosts {
-                        if h.Host == hostVal {
+                        if h.ID == newHost.ID {
                             m.cursor = i
                             break
                         }
                     }
                     // Switch back to list mode
                     m.mode = modeList
-                    // Trigger ping to update status immediately
-                    return m, pingAllCmd(m.hosts)
+                    // Start a timer for a new or changed host to probe it
+                    // immediately; the old entry's timer dies out by itself
+                    if !changed {
+                        return m, nil
+                    }
+                    m.closeUnlisted(time.Now())
+                    return m, hostTickCmd(m.schedGen, newHost.ID, 0)
                 }
                 m.inputDesc, cmd = m.inputDesc.Update(msg)
                 return m, cmd
@@ -549,11 +1158,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             case "y", "Y":
                 // Delete host at confirmIndex
                 if m.confirmIndex >= 0 && m.confirmIndex < len(m.hosts) {
+                    // Remove corresponding result and history as well
+                    m.forget(m.hosts[m.confirmIndex].ID)
                     m.hosts = append(m.hosts[:m.confirmIndex], m.hosts[m.confirmIndex+1:]...)
-                    // Remove corresponding result entry as well
-                    if m.confirmIndex < len(m.results) {
-                        m.results = append(m.results[:m.confirmIndex], m.results[m.confirmIndex+1:]...)
-                    }
+                    m.closeUnlisted(time.Now())
                     // Adjust cursor if necessary
                     if m.cursor >= len(m.hosts) && m.cursor > 0 {
                         m.cursor--
@@ -568,26 +1176,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 return m, nil
             }
         } else if m.mode == modeOptions {
-            // Options mode: adjust ping interval (float seconds) and sort choice (name/ip).
+            // Options mode: adjust ping interval (float seconds), packets per
+            // round, worker limit, packet budget and sort choice (name/ip).
+            // The text inputs come first in focus order, then the sort list.
             var cmd tea.Cmd
-            if m.optFocus == 0 {
-                // Interval input field has focus
+            inputs := m.optInputs()
+            if m.optFocus < len(inputs) {
                 switch msg.String() {
-                case "tab":
-                    m.optInterval.Blur()
-                    m.optFocus = 1
+                case "tab", "enter":
+                    // Move focus to the next input, or the sort choice
+                    inputs[m.optFocus].Blur()
+                    m.optFocus++
+                    if m.optFocus < len(inputs) {
+                        inputs[m.optFocus].Focus()
+                    }
                     return m, nil
                 case "esc":
                     // Cancel options changes
                     m.mode = modeList
                     return m, nil
-                case "enter":
-                    // Move focus to sort choice
-                    m.optInterval.Blur()
-                    m.optFocus = 1
-                    return m, nil
                 }
-                m.optInterval, cmd = m.optInterval.Update(msg)
+                *inputs[m.optFocus], cmd = inputs[m.optFocus].Update(msg)
                 return m, cmd
             }
             // Sort list is focused
@@ -618,33 +1227,83 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                     m.setMessage("Interval must be between 0.5 and 5 seconds")
                     return m, nil
                 }
+                count, err := strconv.Atoi(strings.TrimSpace(m.optCount.Value()))
+                if err != nil || count < 1 || count > maxCount {
+                    m.setMessage(fmt.Sprintf("Packets per round must be between 1 and %d", maxCount))
+                    return m, nil
+                }
+                workers, err := strconv.Atoi(strings.TrimSpace(m.optWorkers.Value()))
+                if err != nil || workers < 1 || workers > maxWorkers {
+                    m.setMessage(fmt.Sprintf("Workers must be between 1 and %d", maxWorkers))
+                    return m, nil
+                }
+                pps, err := strconv.Atoi(strings.TrimSpace(m.optPPS.Value()))
+                if err != nil || pps < 0 || pps > maxPPS {
+                    m.setMessage(fmt.Sprintf("Packets per second must be between 0 (unlimited) and %d", maxPPS))
+                    return m, nil
+                }
+                fall, err := strconv.Atoi(strings.TrimSpace(m.optFall.Value()))
+                if err != nil || fall < 1 || fall > maxThreshold {
+                    m.setMessage(fmt.Sprintf("Down after must be between 1 and %d rounds", maxThreshold))
+                    return m, nil
+                }
+                rise, err := strconv.Atoi(strings.TrimSpace(m.optRise.Value()))
+                if err != nil || rise < 1 || rise > maxThreshold {
+                    m.setMessage(fmt.Sprintf("Up after must be between 1 and %d rounds", maxThreshold))
+                    return m, nil
+                }
+                warnMs, err := strconv.Atoi(strings.TrimSpace(m.optWarn.Value()))
+                if err != nil || warnMs < 0 {
+                    m.setMessage("Warning latency must be a number of milliseconds, 0 for off")
+                    return m, nil
+                }
+                critMs, err := strconv.Atoi(strings.TrimSpace(m.optCrit.Value()))
+                if err != nil || critMs < 0 {
+                    m.setMessage("Critical latency must be a number of milliseconds, 0 for off")
+                    return m, nil
+                }
+                if warnMs > 0 && critMs > 0 && warnMs >= critMs {
+                    m.setMessage("Warning latency must be below the critical latency")
+                    return m, nil
+                }
+                window, err := parseSeconds(strings.TrimSpace(m.optWindow.Value()))
+                if err != nil || window < minStatWindow || window > maxStatWindow {
+                    m.setMessage(fmt.Sprintf("Stats window must be between %v and %v", minStatWindow, maxStatWindow))
+                    return m, nil
+                }
+                statCols, err := parseStatColumns(m.optColumns.Value())
+                if err != nil {
+                    m.setMessage(fmt.Sprintf("Invalid columns: %v", err))
+                    return m, nil
+                }
                 sortStr := sortChoices[m.optSortIndex]
                 // Apply new settings
                 m.interval = dur
+                m.count = count
+                m.workers = workers
+                m.pps = pps
+                m.fall = fall
+                m.rise = rise
+                m.latWarn = time.Duration(warnMs) * time.Millisecond
+                m.latCrit = time.Duration(critMs) * time.Millisecond
+                m.statWindow = window
+                m.statCols = statCols
+                pool.setLimits(workers, pps)
                 m.sortBy = sortStr
-                // Preserve old hosts and results for remapping
-                oldHosts := make([]Host, len(m.hosts))
-                copy(oldHosts, m.hosts)
-                oldResults := make([]pingResult, len(m.results))
-                copy(oldResults, m.results)
-                // Re-sort hosts according to new preference
+                // Re-sort hosts according to new preference. Results are
+                // keyed by host ID and stay attached to their hosts.
                 m.sortHosts()
-                // Remap old results to the new ordering based on host names
-                newResults := make([]pingResult, len(m.hosts))
-                for i, h := range m.hosts {
-                    for j, oh := range oldHosts {
-                        if h.Host == oh.Host && j < len(oldResults) {
-                            newResults[i] = oldResults[j]
-                            break
-                        }
-                    }
-                }
-                m.results = newResults
                 m.cursor = 0
                 // Exit options mode
                 m.mode = modeList
-                // Trigger immediate ping to update statuses and apply new interval
-                return m, pingAllCmd(m.hosts)
+                // A replay only takes the columns and sort order
+                if m.replay != nil {
+                    return m, nil
+                }
+                // Restart the per‑host timers to probe immediately and
+                // apply the new interval
+                m.schedGen++
+                return m, m.scheduleCmd()
             case "up", "k", "K":
                 if m.optSortIndex > 0 {
                     m.optSortIndex--
@@ -658,6 +1317,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             }
             // Ignore other keys while choosing sort option
             return m, nil
+        } else if m.mode == modeDetail {
+            return m.updateDetail(msg)
+        } else if m.mode == modeIncidents {
+            return m.updateIncidents(msg)
+        } else if m.mode == modeExport {
+            return m.updateExport(msg)
         }
     }
     return m, nil
@@ -668,17 +1333,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // based on the longest value in each column.
 // widthFor determines the widths for each column of the table. It bases
 // widths on the longest content currently in that column, while also
-// respecting the header labels. The results slice is consulted for the
+// respecting the header labels. The results map is consulted for the
 // change and age columns. This ensures the table adjusts dynamically as
-// runtime values grow.
-func widthFor(hosts []Host, results []pingResult) (wHost, wDesc, wStatus, wReply, wChange, wAge int) {
+// runtime values grow. wStats holds the widths of the optional statCols.
+// The TREND sparkline fills the rest of termWidth and is 0 when there's no
+// room for it.
+func widthFor(hosts []Host, results map[int]pingResult, statCols []string, termWidth int, now time.Time) (wHost, wDesc, wStatus, wReply, wLoss, wRTT int, wStats []int, wTrend, wChange, wAge, wReason, wInfo int) {
     // Start with header lengths
     wHost = len("HOST")
     wDesc = len("DESC")
     wStatus = len("STATUS")
     wReply = len("REPLY(ms)")
+    wLoss = len("LOSS")
+    wRTT = len("MIN/AVG/MAX/MDEV")
     wChange = len("LAST STATUS CHANGE")
     wAge = len("AGE")
+    wReason = len("REASON")
+    wInfo = len("INFO")
+    wStats = make([]int, len(statCols))
+    for k, key := range statCols {
+        c, _ := statColumnFor(key)
+        wStats[k] = max(len(c.title), statWidth)
+    }
     // Host and description widths
     for _, h := range hosts {
         if l := len(h.Host); l > wHost {
@@ -688,17 +1364,29 @@ func widthFor(hosts []Host, results []pingResult) (wHost, wDesc, wStatus, wReply
             wDesc = l
         }
     }
-    // Status is fixed width of either "UP" or "DOWN". Already handled by header
-    // Reply width depends on the numeric value
-    for _, res := range results {
+    // Status is usually a single word such as "UP" or "DEGRADED", but
+    // dual‑stack hosts may report both families. Reply width depends on the
+    // numeric value.
+    for _, h := range hosts {
+        res := results[h.ID]
+        if l := len(res.statusText()); l > wStatus {
+            wStatus = l
+        }
         // reply printed with one decimal or '-' -> at least 1 char; we consider string length
-        if res.status {
-            if res.reply >= 0 {
-                s := fmt.Sprintf("%.1f", res.reply)
-                if len(s) > wReply {
-                    wReply = len(s)
-                }
-            }
+        if l := len(res.replyText()); l > wReply {
+            wReply = l
+        }
+        if l := len(res.reasonText()); l > wReason {
+            wReason = l
+        }
+        if l := len(res.info); l > wInfo {
+            wInfo = l
+        }
+        if l := len(res.stats.lossText()); l > wLoss {
+            wLoss = l
+        }
+        if l := len(res.stats.rttText()); l > wRTT {
+            wRTT = l
         }
         if !res.lastChange.IsZero() {
             // last change time always formatted as HH:MM:SS (8 chars)
@@ -706,15 +1394,44 @@ func widthFor(hosts []Host, results []pingResult) (wHost, wDesc, wStatus, wReply
                 wChange = 8
             }
             // age as number of seconds since last change
-            ageStr := fmt.Sprintf("%.0f", time.Since(res.lastChange).Seconds())
+            ageStr := fmt.Sprintf("%.0f", now.Sub(res.lastChange).Seconds())
             if len(ageStr) > wAge {
                 wAge = len(ageStr)
             }
         }
     }
+    // The sparkline takes whatever width is left over, within limits. It is
+    // dropped when the terminal is too narrow for it.
+    used := wHost + wDesc + wStatus + wReply + wLoss + wRTT + wChange + wAge + wReason + wInfo + 10*colSep
+    for _, w := range wStats {
+        used += w + colSep
+    }
+    wTrend = min(termWidth-used, maxTrendWidth)
+    if wTrend < len("TREND") {
+        wTrend = 0
+    }
     return
 }
 
+// colSep is the number of spaces between table columns.
+const colSep = 2
+
+// maxTrendWidth caps the width of the sparkline column.
+const maxTrendWidth = 60
+
+// statusLine summarises the probe pool: busy workers, rounds queued for a
+// worker and how long recent host rounds took from queueing to result.
+func (m model) statusLine() string {
+    active, limit, waiting := pool.snapshot()
+    avg, max := m.rounds.summary()
+    line := fmt.Sprintf("workers %d/%d   queue %d   round avg %.1fs max %.1fs",
+        active, limit, waiting, avg.Seconds(), max.Seconds())
+    if m.pps > 0 {
+        line += fmt.Sprintf("   limit %d pkts/s", m.pps)
+    }
+    return line
+}
+
 // View renders the UI based on the current state. It uses lipgloss to
 // center the header, legend and table. Colour is applied to the status
 // column to distinguish up/down hosts. The selected row is highlighted.
@@ -722,6 +1439,12 @@ func (m model) View() string {
     if m.quitting {
         return ""
     }
+    if m.mode == modeDetail {
+        return m.detailView()
+    }
+    if m.mode == modeIncidents {
+        return m.incidentsView()
+    }
     // Build ASCII header
     fig := figure.NewFigure("MPING", "", true)
     headerLines := strings.Split(fig.String(), "\n")
@@ -741,31 +1464,62 @@ func (m model) View() string {
         header += centerLine(hdrStyle.Render(line)) + "\n"
     }
     // Legend
-    legend := "A Add   E Edit   D Delete   S Save   R Reload   O Options   Q Quit"
+    legend := "Enter Chart   I Incidents   X Export   A Add   E Edit   D Delete   S Save   R Reload   O Options   Q Quit"
+    status := m.statusLine()
+    if m.replay != nil {
+        legend = "Space Play/Pause   +/- Speed   ←/→ [/] {/} Seek 1m/10m/1h   Enter Chart   I Incidents   X Export   O Options   Q Quit"
+        status = m.replayLine(width)
+    }
     legendStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("7")).Bold(true)
-    header += centerLine(legendStyle.Render(legend)) + "\n\n"
+    header += centerLine(legendStyle.Render(legend)) + "\n"
+    header += centerLine(lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Render(status)) + "\n\n"
     // Table column widths
-    wHost, wDesc, wStatus, wReply, wChange, wAge := widthFor(m.hosts, m.results)
-    // Spacing between columns
-    colSep := 2
-    // Compose header row
-    headerRow := fmt.Sprintf(
-        "%-*s%s%-*s%s%-*s%s%*s%s%*s%s%*s",
-        wHost, "HOST", strings.Repeat(" ", colSep),
-        wDesc, "DESC", strings.Repeat(" ", colSep),
-        wStatus, "STATUS", strings.Repeat(" ", colSep),
-        wReply, "REPLY(ms)", strings.Repeat(" ", colSep),
-        wChange, "LAST STATUS CHANGE", strings.Repeat(" ", colSep),
-        wAge, "AGE",
-    )
+    wHost, wDesc, wStatus, wReply, wLoss, wRTT, wStats, wTrend, wChange, wAge, wReason, wInfo := widthFor(m.hosts, m.results, m.statCols, width, m.now())
+    // Compose header row. The TREND column is only present if it fits.
+    headerCols := []string{
+        fmt.Sprintf("%-*s", wHost, "HOST"),
+        fmt.Sprintf("%-*s", wDesc, "DESC"),
+        fmt.Sprintf("%-*s", wStatus, "STATUS"),
+        fmt.Sprintf("%*s", wReply, "REPLY(ms)"),
+        fmt.Sprintf("%*s", wLoss, "LOSS"),
+        fmt.Sprintf("%*s", wRTT, "MIN/AVG/MAX/MDEV"),
+        fmt.Sprintf("%*s", wChange, "LAST STATUS CHANGE"),
+        fmt.Sprintf("%*s", wAge, "AGE"),
+        fmt.Sprintf("%-*s", wReason, "REASON"),
+        fmt.Sprintf("%-*s", wInfo, "INFO"),
+    }
+    // Optional latency statistics follow MIN/AVG/MAX/MDEV, then the TREND
+    // column if it fits.
+    var statCols []statColumn
+    for k, key := range m.statCols {
+        c, _ := statColumnFor(key)
+        statCols = append(statCols, c)
+        headerCols = slices.Insert(headerCols, 6+k, fmt.Sprintf("%*s", wStats[k], c.title))
+    }
+    if wTrend > 0 {
+        headerCols = slices.Insert(headerCols, 6+len(statCols), fmt.Sprintf("%-*s", wTrend, "TREND"))
+    }
+    headerRow := strings.Join(headerCols, strings.Repeat(" ", colSep))
     // Build rows. We'll construct each column separately, pad it to its width
     // and apply colouring and selection styles after. To avoid overflowing
     // the terminal height when many hosts are present, we compute how many
     // rows can fit below the header and message areas.
     var rows []string
     // Styles for statuses
-    upStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
-    downStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+    stateStyles := map[hostState]lipgloss.Style{
+        statePending:  lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+        stateUp:       lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true),
+        stateDegraded: lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Bold(true),
+        stateDown:     lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true),
+        stateError:    lipgloss.NewStyle().Foreground(lipgloss.Color("5")).Bold(true),
+    }
+    // Hosts whose latest rounds disagree with their state
+    suspectStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true)
+    // Hosts whose state keeps changing
+    flapStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Bold(true)
+    // Hosts that are up but flagged with a warning, or critically slow
+    warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+    critStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("202")).Bold(true)
     // Selection background style (only background colour so that per‑column
     // foreground colouring remains visible)
     selectedBg := lipgloss.NewStyle().Background(lipgloss.Color("4"))
@@ -802,63 +1556,93 @@ func (m model) View() string {
     for idx := start; idx < end; idx++ {
         h := m.hosts[idx]
         // Determine status and prepare padded plain text for status
-        statusPlain := "DOWN"
-        reply := "-"
         change := "-"
         age := "-"
-        res := pingResult{}
-        if idx < len(m.results) {
-            res = m.results[idx]
-        }
-        if res.status {
-            statusPlain = "UP"
-            if res.reply >= 0 {
-                reply = fmt.Sprintf("%.1f", res.reply)
-            }
-        }
+        res := m.results[h.ID]
+        statusPlain := res.statusText()
+        reply := res.replyText()
         if !res.lastChange.IsZero() {
             change = res.lastChange.Format("15:04:05")
-            age = fmt.Sprintf("%.0f", time.Since(res.lastChange).Seconds())
+            age = fmt.Sprintf("%.0f", m.now().Sub(res.lastChange).Seconds())
         }
         // Pad each column
         hostCol := fmt.Sprintf("%-*s", wHost, h.Host)
         descCol := fmt.Sprintf("%-*s", wDesc, h.Desc)
         // Status column padded and then coloured
         statusColPlain := fmt.Sprintf("%-*s", wStatus, statusPlain)
-        var statusCol string
-        if res.status {
-            statusCol = upStyle.Render(statusColPlain)
-        } else {
-            statusCol = downStyle.Render(statusColPlain)
+        statusCol := stateStyles[res.state].Render(statusColPlain)
+        if res.flapping {
+            statusCol = flapStyle.Render(statusColPlain)
+        } else if res.suspect {
+            statusCol = suspectStyle.Render(statusColPlain)
+        } else if res.critical && res.up() {
+            statusCol = critStyle.Render(statusColPlain)
         }
         replyCol := fmt.Sprintf("%*s", wReply, reply)
+        lossCol := fmt.Sprintf("%*s", wLoss, res.stats.lossText())
+        rttCol := fmt.Sprintf("%*s", wRTT, res.stats.rttText())
         changeCol := fmt.Sprintf("%*s", wChange, change)
         ageCol := fmt.Sprintf("%*s", wAge, age)
-        parts := []string{hostCol, descCol, statusCol, replyCol, changeCol, ageCol}
+        reason := res.reasonText()
+        if reason == "" {
+            reason = "-"
+        }
+        reasonCol := fmt.Sprintf("%-*s", wReason, reason)
+        info := res.info
+        if info == "" {
+            info = "-"
+        }
+        infoCol := fmt.Sprintf("%-*s", wInfo, info)
+        parts := []string{hostCol, descCol, statusCol, replyCol, lossCol, rttCol, changeCol, ageCol, reasonCol, infoCol}
+        // Latency statistics are only computed for the rows shown
+        if len(statCols) > 0 {
+            lat := m.latencyStatsFor(h)
+            for k, c := range statCols {
+                parts = slices.Insert(parts, 6+k, fmt.Sprintf("%*s", wStats[k], c.text(res, lat)))
+            }
+        }
+        if wTrend > 0 {
+            parts = slices.Insert(parts, 6+len(statCols), sparkline(m.history[h.ID], wTrend))
+        }
+        // Draw the rest of the row in the warning or critical colour as
+        // well
+        if res.up() && (res.warn || res.critical) {
+            rowStyle := warnStyle
+            if res.critical {
+                rowStyle = critStyle.UnsetBold()
+            }
+            for j := range parts {
+                if j == 2 {
+                    continue
+                }
+                parts[j] = rowStyle.Render(parts[j])
+            }
+        }
         // Apply flash highlight if status recently changed and this row is not selected
-        if idx < len(m.results) {
-            res := m.results[idx]
-            if res.flashUntil.After(time.Now()) && (m.mode != modeList || idx != m.cursor) {
-                // Highlight the row when a status changes by adding a coloured
-                // background and bold text to all cells except the status
-                // column. This preserves the coloured status text while still
-                // drawing the user's attention to the change.
-                var fs lipgloss.Style
-                if res.status {
-                    // Green background for hosts that are now UP.
-                    fs = lipgloss.NewStyle().Background(lipgloss.Color("10")).Bold(true)
-                } else {
-                    // Red background for hosts that went DOWN.
-                    fs = lipgloss.NewStyle().Background(lipgloss.Color("1")).Bold(true)
-                }
-                for j := range parts {
-                    // Skip the status column (index 2) to retain its
-                    // existing colour.
-                    if j == 2 {
-                        continue
-                    }
-                    parts[j] = fs.Render(parts[j])
+        if res.flashUntil.After(m.now()) && (m.mode != modeList || idx != m.cursor) {
+            // Highlight the row when a status changes by adding a coloured
+            // background and bold text to all cells except the status
+            // column. This preserves the coloured status text while still
+            // drawing the user's attention to the change.
+            var fs lipgloss.Style
+            switch res.state {
+            case stateUp:
+                // Green background for hosts that are now UP.
+                fs = lipgloss.NewStyle().Background(lipgloss.Color("10")).Bold(true)
+            case stateDegraded:
+                // Yellow background for hosts that became DEGRADED.
+                fs = lipgloss.NewStyle().Background(lipgloss.Color("3")).Bold(true)
+            default:
+                // Red background for hosts that went DOWN or into ERROR.
+                fs = lipgloss.NewStyle().Background(lipgloss.Color("1")).Bold(true)
+            }
+            for j := range parts {
+                // Skip the status column (index 2) to retain its
+                // existing colour.
+                if j == 2 {
+                    continue
                 }
+                parts[j] = fs.Render(parts[j])
             }
         }
         // Apply selection background if this row is selected in list mode
@@ -894,7 +1678,16 @@ func (m model) View() string {
         }
     } else if m.mode == modeOptions {
         overlay = "Options:\n"
-        overlay += "Interval: " + m.optInterval.View() + "\n"
+        overlay += "Interval:    " + m.optInterval.View() + "\n"
+        overlay += "Packets:     " + m.optCount.View() + "\n"
+        overlay += "Workers:     " + m.optWorkers.View() + "\n"
+        overlay += "Pkts/s:      " + m.optPPS.View() + "\n"
+        overlay += "Down after:  " + m.optFall.View() + "\n"
+        overlay += "Up after:    " + m.optRise.View() + "\n"
+        overlay += "Warn (ms):   " + m.optWarn.View() + "\n"
+        overlay += "Crit (ms):   " + m.optCrit.View() + "\n"
+        overlay += "Window:      " + m.optWindow.View() + "\n"
+        overlay += "Columns:     " + m.optColumns.View() + "\n"
         overlay += "Sort by:\n"
         for i, choice := range sortChoices {
             prefix := "  "
@@ -905,6 +1698,13 @@ func (m model) View() string {
             overlay += prefix + display + "\n"
         }
         overlay += "Press Tab to switch, Up/Down to choose, Enter to confirm, Esc to cancel"
+    } else if m.mode == modeExport {
+        overlay = "Export status and history:\n"
+        overlay += "Hosts: " + m.exportHost.View() + "\n"
+        overlay += "From:  " + m.exportFrom.View() + "\n"
+        overlay += "To:    " + m.exportTo.View() + "\n"
+        overlay += "File:  " + m.exportFile.View() + "\n"
+        overlay += "Press Tab to switch, Enter on File to export, Esc to cancel"
     }
     // Compose final view
     var out strings.Builder
@@ -929,26 +1729,52 @@ func (m model) View() string {
 
 // main entry point: loads hosts, constructs model and runs the TUI.
 func main() {
+    if len(os.Args) > 1 {
+        switch os.Args[1] {
+        case "replay":
+            runReplay(os.Args[2:])
+            return
+        case "export":
+            runExport(os.Args[2:])
+            return
+        }
+    }
     hosts, err := loadHostsFromFile("hosts.txt")
     if err != nil && !os.IsNotExist(err) {
         fmt.Fprintf(os.Stderr, "Failed to load hosts: %v\n", err)
         os.Exit(1)
     }
-    // Initialise default ping results slice
-    results := make([]pingResult, len(hosts))
     m := model{
-        hosts:    hosts,
-        results:  results,
-        cursor:   0,
-        interval: 5 * time.Second,
-        mode:     modeList,
-        sortBy:   "name",
+        hosts:      hosts,
+        results:    make(map[int]pingResult),
+        history:    make(map[int]*history),
+        uptime:     make(map[int]*uptime),
+        cursor:     0,
+        interval:   5 * time.Second,
+        count:      1,
+        workers:    defaultWorkers,
+        fall:       1,
+        rise:       1,
+        statWindow: defaultStatWindow,
+        mode:       modeList,
+        sortBy:     "name",
     }
+    m.assignIDs(m.hosts)
     // Ensure initial host list is sorted alphabetically
     m.sortHosts()
+    // Record results on disk and pick up where the last run left off. mping
+    // still works without the store, just forgetting everything on exit.
+    if st, err := openStore(dataDir); err != nil {
+        fmt.Fprintf(os.Stderr, "Not recording results: %v\n", err)
+    } else {
+        m.store = st
+        m.setMessage(loadingHistory)
+        defer st.close()
+    }
     p := tea.NewProgram(m, tea.WithAltScreen())
     if _, err := p.Run(); err != nil {
         fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
+        m.store.close()
         os.Exit(1)
     }
 }
\ No newline at end of file
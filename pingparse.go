@@ -0,0 +1,292 @@
+package main
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// pingReply is one line of ping output turned into data: either an echo
+// reply or an error reported for a sequence number.
+type pingReply struct {
+    seq  int         // sequence number, or the reply's position if the output has none
+    ttl  int         // TTL or hop limit, 0 if not reported
+    rtt  float64     // round‑trip time in milliseconds, -1 if not reported
+    fail failureKind // failNone for an echo reply
+
+    // reason describes an error for the REASON column, e.g.
+    // "host unreachable from 10.0.0.1".
+    reason string
+}
+
+// pingParser understands the output of one ping implementation.
+type pingParser interface {
+    // detect reports whether out looks like this implementation's output.
+    detect(out string) bool
+    // parse extracts all replies and errors from out.
+    parse(out string) []pingReply
+}
+
+// pingParsers is the parser registry, keyed by implementation name.
+var pingParsers = map[string]pingParser{
+    "iputils": iputilsParser{},
+    "bsd":     bsdParser{},
+    "busybox": busyboxParser{},
+    "windows": windowsParser{},
+}
+
+// parserOrder lists the candidates tried per platform, most likely first.
+// Implementations that aren't the platform default (busybox on Linux, say)
+// are recognised by detect; the last entry is the fallback.
+var parserOrder = map[string][]string{
+    "linux":   {"busybox", "iputils"},
+    "windows": {"windows"},
+    "darwin":  {"bsd"},
+    "freebsd": {"bsd"},
+    "openbsd": {"bsd"},
+    "netbsd":  {"bsd"},
+}
+
+// parserFor picks the parser for the given platform and output.
+func parserFor(goos, out string) pingParser {
+    names, ok := parserOrder[goos]
+    if !ok {
+        names = []string{"busybox", "bsd", "iputils"}
+    }
+    for _, n := range names {
+        if p := pingParsers[n]; p.detect(out) {
+            return p
+        }
+    }
+    return pingParsers[names[len(names)-1]]
+}
+
+// parsePingOutput parses ping output with the parser appropriate for goos.
+func parsePingOutput(goos, out string) []pingReply {
+    return parserFor(goos, out).parse(out)
+}
+
+var (
+    reSeq     = regexp.MustCompile(`(?:icmp_seq|seq)[= ](\d+)`)
+    reTTL     = regexp.MustCompile(`(?i)(?:ttl|hlim)=(\d+)`)
+    reTimeMs  = regexp.MustCompile(`time[=<]\s*(\d+(?:\.\d+)?)\s*ms`)
+    reFromLen = regexp.MustCompile(`^\d+ bytes from `)
+    reUnreach = regexp.MustCompile(`(?i)destination (net|host|port|protocol) (unreachable|prohibited)`)
+    reFromHop = regexp.MustCompile(`(?i)\b(?:from|von) (\S+?):?(?:\s|$)`)
+)
+
+// submatchInt returns the first group of re in line as an int, or def.
+func submatchInt(re *regexp.Regexp, line string, def int) int {
+    if m := re.FindStringSubmatch(line); m != nil {
+        if v, err := strconv.Atoi(m[1]); err == nil {
+            return v
+        }
+    }
+    return def
+}
+
+// submatchFloat returns the first group of re in line as a float, or def.
+// Decimal commas are accepted.
+func submatchFloat(re *regexp.Regexp, line string, def float64) float64 {
+    if m := re.FindStringSubmatch(line); m != nil {
+        if v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", "."), 64); err == nil {
+            return v
+        }
+    }
+    return def
+}
+
+// unixErrorKind classifies the error lines shared by the Unix implementations.
+func unixErrorKind(line string) failureKind {
+    l := strings.ToLower(line)
+    switch {
+    case strings.Contains(l, "unknown host"), strings.Contains(l, "cannot resolve"),
+        strings.Contains(l, "name or service not known"), strings.Contains(l, "bad address"),
+        strings.Contains(l, "temporary failure in name resolution"), strings.Contains(l, "no address associated"):
+        return failDNS
+    case strings.Contains(l, "operation not permitted"), strings.Contains(l, "permission denied"):
+        return failPermission
+    case strings.Contains(l, "unreachable"):
+        return failUnreachable
+    case strings.Contains(l, "time to live exceeded"):
+        return failTTLExceeded
+    case strings.Contains(l, "request timeout"):
+        return failTimeout
+    }
+    return failNone
+}
+
+// errorReason describes an error line of kind for the REASON column. For
+// unreachable and TTL exceeded errors it names the kind of unreachable where
+// the output tells, and the router that reported the error.
+func errorReason(kind failureKind, line string) string {
+    reason := kind.String()
+    if kind != failUnreachable && kind != failTTLExceeded {
+        return reason
+    }
+    if m := reUnreach.FindStringSubmatch(line); m != nil && kind == failUnreachable {
+        reason = strings.ToLower(m[1] + " " + m[2])
+    }
+    if m := reFromHop.FindStringSubmatch(line); m != nil {
+        reason += " from " + m[1]
+    }
+    return reason
+}
+
+// iputilsParser handles Linux iputils ping:
+//
+//    64 bytes from 127.0.0.1: icmp_seq=1 ttl=64 time=0.045 ms
+//    From 10.0.0.1 icmp_seq=1 Destination Host Unreachable
+type iputilsParser struct{}
+
+func (iputilsParser) detect(out string) bool {
+    return strings.Contains(out, ") bytes of data.")
+}
+
+func (iputilsParser) parse(out string) []pingReply {
+    var replies []pingReply
+    for _, line := range strings.Split(out, "\n") {
+        line = strings.TrimSpace(line)
+        switch {
+        case reFromLen.MatchString(line) && strings.Contains(line, "time"):
+            replies = append(replies, pingReply{
+                seq: submatchInt(reSeq, line, len(replies)+1),
+                ttl: submatchInt(reTTL, line, 0),
+                rtt: submatchFloat(reTimeMs, line, -1),
+            })
+        case strings.HasPrefix(line, "From ") || strings.HasPrefix(line, "ping: "):
+            if kind := unixErrorKind(line); kind != failNone {
+                replies = append(replies, pingReply{seq: submatchInt(reSeq, line, len(replies)+1), rtt: -1, fail: kind, reason: errorReason(kind, line)})
+            }
+        }
+    }
+    return replies
+}
+
+// bsdParser handles the BSD ping found on macOS and the BSDs:
+//
+//    64 bytes from 1.1.1.1: icmp_seq=0 ttl=57 time=11.123 ms
+//    Request timeout for icmp_seq 0
+//    92 bytes from 10.0.0.1: Destination Host Unreachable
+type bsdParser struct{}
+
+func (bsdParser) detect(out string) bool {
+    return strings.Contains(out, "Request timeout for icmp_seq") ||
+        (strings.Contains(out, "icmp_seq=") && !strings.Contains(out, ") bytes of data."))
+}
+
+func (bsdParser) parse(out string) []pingReply {
+    var replies []pingReply
+    for _, line := range strings.Split(out, "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "PING ") || strings.HasPrefix(line, "---") {
+            continue
+        }
+        if reFromLen.MatchString(line) && strings.Contains(line, "time=") {
+            replies = append(replies, pingReply{
+                seq: submatchInt(reSeq, line, len(replies)),
+                ttl: submatchInt(reTTL, line, 0),
+                rtt: submatchFloat(reTimeMs, line, -1),
+            })
+            continue
+        }
+        if kind := unixErrorKind(line); kind != failNone {
+            replies = append(replies, pingReply{seq: submatchInt(reSeq, line, len(replies)), rtt: -1, fail: kind, reason: errorReason(kind, line)})
+        }
+    }
+    return replies
+}
+
+// busyboxParser handles busybox ping as found on Alpine and embedded systems:
+//
+//    PING 127.0.0.1 (127.0.0.1): 56 data bytes
+//    64 bytes from 127.0.0.1: seq=0 ttl=64 time=0.060 ms
+type busyboxParser struct{}
+
+func (busyboxParser) detect(out string) bool {
+    return strings.Contains(out, ": seq=") || strings.Contains(out, "bad address")
+}
+
+func (busyboxParser) parse(out string) []pingReply {
+    var replies []pingReply
+    for _, line := range strings.Split(out, "\n") {
+        line = strings.TrimSpace(line)
+        if reFromLen.MatchString(line) && strings.Contains(line, "time=") {
+            replies = append(replies, pingReply{
+                seq: submatchInt(reSeq, line, len(replies)),
+                ttl: submatchInt(reTTL, line, 0),
+                rtt: submatchFloat(reTimeMs, line, -1),
+            })
+            continue
+        }
+        if strings.HasPrefix(line, "ping: ") {
+            if kind := unixErrorKind(line); kind != failNone {
+                replies = append(replies, pingReply{seq: len(replies), rtt: -1, fail: kind, reason: errorReason(kind, line)})
+            }
+        }
+    }
+    return replies
+}
+
+// windowsParser handles Windows ping.exe in several display languages:
+//
+//    Reply from 1.1.1.1: bytes=32 time=11ms TTL=57
+//    Antwort von 1.1.1.1: Bytes=32 Zeit<1ms TTL=128
+//    Reply from ::1: time<1ms
+//
+// IPv6 replies carry no TTL, so a reply is recognised by its time field. The
+// output has no sequence numbers; replies are numbered in order.
+type windowsParser struct{}
+
+var (
+    reWinTime = regexp.MustCompile(`(?i)(?:time|zeit|temps|tiempo|tempo|tijd|czas|время)\s*[=<]\s*(\d+(?:[.,]\d+)?)\s*ms`)
+    // Error phrases by kind, lower case, covering English, German, French
+    // and Spanish installations.
+    winErrors = []struct {
+        kind    failureKind
+        phrases []string
+    }{
+        {failDNS, []string{"could not find host", "konnte host", "n'a pas pu trouver l'hôte", "no pudo encontrar el host"}},
+        {failTimeout, []string{"request timed out", "zeitüberschreitung der anforderung", "délai d'attente de la demande dépassé", "tiempo de espera agotado"}},
+        {failTTLExceeded, []string{"ttl expired in transit", "ttl beim übertragen abgelaufen", "durée de vie ttl expirée", "ttl caducó durante el tránsito"}},
+        {failUnreachable, []string{"unreachable", "nicht erreichbar", "inaccessible", "inaccesible"}},
+        {failPermission, []string{"access denied", "zugriff verweigert", "accès refusé", "acceso denegado"}},
+    }
+)
+
+func (windowsParser) detect(out string) bool {
+    return reWinTime.MatchString(out) || strings.Contains(strings.ToLower(out), "bytes=")
+}
+
+func (windowsParser) parse(out string) []pingReply {
+    var replies []pingReply
+    for _, line := range strings.Split(out, "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" {
+            continue
+        }
+        if reWinTime.MatchString(line) {
+            replies = append(replies, pingReply{
+                seq: len(replies) + 1,
+                ttl: submatchInt(reTTL, line, 0),
+                rtt: submatchFloat(reWinTime, line, -1),
+            })
+            continue
+        }
+        l := strings.ToLower(line)
+        for _, e := range winErrors {
+            matched := false
+            for _, p := range e.phrases {
+                if strings.Contains(l, p) {
+                    matched = true
+                    break
+                }
+            }
+            if matched {
+                replies = append(replies, pingReply{seq: len(replies) + 1, rtt: -1, fail: e.kind, reason: errorReason(e.kind, line)})
+                break
+            }
+        }
+    }
+    return replies
+}
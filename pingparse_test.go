@@ -0,0 +1,128 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// Captured ping output lives in testdata/ping, one file per implementation
+// and case. The Windows captures keep their CRLF line endings.
+
+func TestParsePingOutput(t *testing.T) {
+    ok := func(seq, ttl int, rtt float64) pingReply {
+        return pingReply{seq: seq, ttl: ttl, rtt: rtt}
+    }
+    fail := func(seq int, kind failureKind, reason string) pingReply {
+        return pingReply{seq: seq, rtt: -1, fail: kind, reason: reason}
+    }
+    tests := []struct {
+        file string
+        goos string
+        want []pingReply
+    }{
+        // Linux iputils
+        {"iputils_ok.txt", "linux", []pingReply{ok(1, 57, 11.2), ok(2, 57, 10.9), ok(3, 57, 12.4)}},
+        {"iputils_loss.txt", "linux", []pingReply{ok(1, 49, 98.7), ok(3, 49, 101), ok(3, 49, 101)}},
+        {"iputils_ipv6.txt", "linux", []pingReply{ok(1, 64, 0.031), ok(2, 64, 0.047)}},
+        {"iputils_unreachable.txt", "linux", []pingReply{
+            fail(1, failUnreachable, "host unreachable from 10.0.0.1"),
+            fail(2, failUnreachable, "host unreachable from 10.0.0.1"),
+        }},
+        {"iputils_ttl.txt", "linux", []pingReply{fail(1, failTTLExceeded, "TTL exceeded from 192.168.1.1")}},
+        {"iputils_unknown.txt", "linux", []pingReply{fail(1, failDNS, "DNS failed")}},
+
+        // BSD and macOS
+        {"bsd_ok.txt", "darwin", []pingReply{ok(0, 57, 11.123), ok(1, 57, 10.875)}},
+        {"bsd_timeout.txt", "darwin", []pingReply{fail(0, failTimeout, "timeout"), fail(1, failTimeout, "timeout")}},
+        {"bsd_unreachable.txt", "darwin", []pingReply{fail(0, failUnreachable, "host unreachable from 192.168.1.10")}},
+        {"bsd_ttl.txt", "freebsd", []pingReply{fail(0, failTTLExceeded, "TTL exceeded from 192.168.1.1")}},
+        {"bsd_unknown.txt", "darwin", []pingReply{fail(0, failDNS, "DNS failed")}},
+
+        // busybox, recognised on Linux by its output
+        {"busybox_ok.txt", "linux", []pingReply{ok(0, 64, 0.060), ok(1, 64, 0.081)}},
+        {"busybox_unknown.txt", "linux", []pingReply{fail(0, failDNS, "DNS failed")}},
+
+        // Windows, English and German
+        {"windows_en_ok.txt", "windows", []pingReply{ok(1, 57, 11), ok(2, 57, 12), ok(3, 57, 11)}},
+        {"windows_en_ipv6.txt", "windows", []pingReply{ok(1, 0, 1), ok(2, 0, 1)}},
+        {"windows_en_errors.txt", "windows", []pingReply{
+            fail(1, failUnreachable, "host unreachable from 10.0.0.1"),
+            fail(2, failTimeout, "timeout"),
+            fail(3, failTTLExceeded, "TTL exceeded from 192.168.1.1"),
+        }},
+        {"windows_en_unknown.txt", "windows", []pingReply{fail(1, failDNS, "DNS failed")}},
+        {"windows_de_ok.txt", "windows", []pingReply{ok(1, 64, 3), ok(2, 64, 1)}},
+        {"windows_de_errors.txt", "windows", []pingReply{
+            fail(1, failUnreachable, "unreachable from 10.0.0.1"),
+            fail(2, failTimeout, "timeout"),
+            fail(3, failTTLExceeded, "TTL exceeded from 192.168.1.1"),
+        }},
+        {"windows_de_unknown.txt", "windows", []pingReply{fail(1, failDNS, "DNS failed")}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.file, func(t *testing.T) {
+            out, err := os.ReadFile(filepath.Join("testdata", "ping", tt.file))
+            if err != nil {
+                t.Fatal(err)
+            }
+            got := parsePingOutput(tt.goos, string(out))
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("parsePingOutput(%q) =\n  %+v\nwant\n  %+v", tt.goos, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestReplyRound(t *testing.T) {
+    ok := func(seq int, rtt float64) pingReply {
+        return pingReply{seq: seq, ttl: 57, rtt: rtt}
+    }
+    fail := func(seq int, kind failureKind, reason string) pingReply {
+        return pingReply{seq: seq, rtt: -1, fail: kind, reason: reason}
+    }
+    tests := []struct {
+        name    string
+        replies []pingReply
+        count   int
+        up      bool
+        recv    int
+        rtts    []float64
+        reason  string // for failures
+    }{
+        {"all", []pingReply{ok(1, 10), ok(2, 20), ok(3, 30)}, 3, true, 3, []float64{10, 20, 30}, ""},
+        {"loss and duplicate", []pingReply{ok(1, 10), ok(3, 30), ok(3, 30)}, 3, true, 2, []float64{10, 30}, ""},
+        {"late reply", []pingReply{fail(0, failTimeout, "timeout"), ok(0, 1500), ok(1, 10)}, 2, true, 2, []float64{1500, 10}, ""},
+        {"more than sent", []pingReply{ok(1, 10), ok(2, 20)}, 1, true, 1, []float64{10}, ""},
+        {"no rtt", []pingReply{ok(1, -1)}, 1, true, 1, nil, ""},
+        {"first error", []pingReply{
+            fail(1, failUnreachable, "host unreachable from 10.0.0.1"),
+            fail(2, failTTLExceeded, "TTL exceeded from 10.0.0.2"),
+        }, 2, false, 0, nil, "host unreachable from 10.0.0.1"},
+        {"silence", nil, 3, false, 0, nil, "timeout"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            res, rtts := replyRound(tt.replies, tt.count)
+            if res.status != tt.up {
+                t.Fatalf("status = %v (reason %q), want %v", res.status, res.reason, tt.up)
+            }
+            if !tt.up && res.reason != tt.reason {
+                t.Errorf("reason = %q, want %q", res.reason, tt.reason)
+            }
+            if res.stats.sent != tt.count || res.stats.recv != tt.recv {
+                t.Errorf("sent/recv = %d/%d, want %d/%d", res.stats.sent, res.stats.recv, tt.count, tt.recv)
+            }
+            if !reflect.DeepEqual(rtts, tt.rtts) {
+                t.Errorf("rtts = %v, want %v", rtts, tt.rtts)
+            }
+            if tt.up && len(tt.rtts) > 0 && res.reply != res.stats.avg {
+                t.Errorf("reply = %v, want the average %v", res.reply, res.stats.avg)
+            }
+            if tt.up && res.info != "ttl 57" {
+                t.Errorf("info = %q, want the TTL", res.info)
+            }
+        })
+    }
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// Default limits for the probe pool. They can be changed at runtime in the
+// options dialog.
+const (
+    defaultWorkers = 256
+    maxWorkers     = 4096
+    maxPPS         = 100000
+)
+
+// probePool bounds how many host rounds run at once and paces individual
+// packets to a global packets‑per‑second budget. Rounds beyond the worker
+// limit queue up in arrival order; the UI shows how deep that queue is.
+type probePool struct {
+    mu      sync.Mutex
+    cond    *sync.Cond
+    limit   int // maximum concurrent rounds
+    active  int // rounds currently running
+    waiting int // rounds queued for a worker
+
+    pps  int       // packets per second, 0 for unlimited
+    next time.Time // earliest time the next packet may leave
+}
+
+// pool is shared by all probes of the program.
+var pool = newProbePool(defaultWorkers, 0)
+
+// newProbePool returns a pool with the given worker limit and packet budget.
+func newProbePool(workers, pps int) *probePool {
+    p := &probePool{limit: workers, pps: pps}
+    p.cond = sync.NewCond(&p.mu)
+    return p
+}
+
+// setLimits changes the worker limit and packet budget. Waiting rounds are
+// woken so a raised limit takes effect immediately.
+func (p *probePool) setLimits(workers, pps int) {
+    p.mu.Lock()
+    p.limit = workers
+    p.pps = pps
+    p.mu.Unlock()
+    p.cond.Broadcast()
+}
+
+// acquire blocks until a worker is free and claims it.
+func (p *probePool) acquire() {
+    p.mu.Lock()
+    p.waiting++
+    for p.active >= p.limit {
+        p.cond.Wait()
+    }
+    p.waiting--
+    p.active++
+    p.mu.Unlock()
+}
+
+// release returns a worker claimed by acquire.
+func (p *probePool) release() {
+    p.mu.Lock()
+    p.active--
+    p.mu.Unlock()
+    p.cond.Signal()
+}
+
+// pace blocks until the packet budget allows n more packets to be sent, and
+// reserves them. Packets are spread evenly at 1/pps apart rather than sent in
+// bursts; n > 1 reserves the time for a burst that a system ping sends by
+// itself, so the packets after it wait for that many slots.
+func (p *probePool) pace(n int) {
+    p.mu.Lock()
+    if p.pps <= 0 {
+        p.mu.Unlock()
+        return
+    }
+    now := time.Now()
+    if p.next.Before(now) {
+        p.next = now
+    }
+    at := p.next
+    p.next = p.next.Add(time.Duration(max(n, 1)) * time.Second / time.Duration(p.pps))
+    p.mu.Unlock()
+    time.Sleep(time.Until(at))
+}
+
+// snapshot returns the current worker usage and queue depth.
+func (p *probePool) snapshot() (active, limit, waiting int) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.active, p.limit, p.waiting
+}
+
+// roundStatsWindow is how many recent host rounds the duration figures in
+// the status line are computed over.
+const roundStatsWindow = 256
+
+// roundTimes keeps the durations of recent host rounds, measured from the
+// moment a round was queued until its result arrived.
+type roundTimes struct {
+    durs []time.Duration
+    pos  int
+}
+
+// add records the duration of a finished round.
+func (r *roundTimes) add(d time.Duration) {
+    if len(r.durs) < roundStatsWindow {
+        r.durs = append(r.durs, d)
+        return
+    }
+    r.durs[r.pos] = d
+    r.pos = (r.pos + 1) % roundStatsWindow
+}
+
+// summary returns the average and maximum of the recorded durations.
+func (r roundTimes) summary() (avg, max time.Duration) {
+    if len(r.durs) == 0 {
+        return 0, 0
+    }
+    var sum time.Duration
+    for _, d := range r.durs {
+        sum += d
+        if d > max {
+            max = d
+        }
+    }
+    return sum / time.Duration(len(r.durs)), max
+}
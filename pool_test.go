@@ -0,0 +1,29 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestPaceReservesRound(t *testing.T) {
+    p := newProbePool(1, 100)
+    start := time.Now()
+    // A system ping of ten packets takes ten slots of 10 ms; the packet
+    // after it waits for all of them.
+    p.pace(10)
+    if d := time.Since(start); d > 5*time.Millisecond {
+        t.Errorf("first reservation waited %v", d)
+    }
+    p.pace(1)
+    if d := time.Since(start); d < 95*time.Millisecond {
+        t.Errorf("packet after a round of ten waited %v, want about 100ms", d)
+    }
+
+    unlimited := newProbePool(1, 0)
+    start = time.Now()
+    unlimited.pace(1000)
+    unlimited.pace(1)
+    if d := time.Since(start); d > 5*time.Millisecond {
+        t.Errorf("unlimited pool waited %v", d)
+    }
+}
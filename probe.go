@@ -0,0 +1,287 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/url"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// probeTimeout bounds a single probe of any kind, name resolution included.
+const probeTimeout = 2 * time.Second
+
+// packetGap spaces the probes of one round so they don't leave in a burst.
+const packetGap = 100 * time.Millisecond
+
+// maxCount limits the packets per round, keeping a round well within the
+// longest ping interval.
+const maxCount = 10
+
+// failureKind classifies why a probe failed.
+type failureKind int
+
+const (
+    failNone        failureKind = iota
+    failTimeout                 // no reply within the timeout
+    failUnreachable             // ICMP destination unreachable
+    failTTLExceeded             // ICMP time exceeded in transit
+    failDNS                     // host name could not be resolved
+    failPermission              // not allowed to send
+    failExec                    // ping binary missing or unusable
+    failRefused                 // connection refused
+    failCheck                   // answered, but the answer failed the check
+    failOther                   // anything else
+)
+
+// String returns the default reason shown for a failure kind.
+func (k failureKind) String() string {
+    switch k {
+    case failTimeout:
+        return "timeout"
+    case failUnreachable:
+        return "unreachable"
+    case failTTLExceeded:
+        return "TTL exceeded"
+    case failDNS:
+        return "DNS failed"
+    case failPermission:
+        return "permission denied"
+    case failExec:
+        return "ping unavailable"
+    case failRefused:
+        return "refused"
+    case failCheck:
+        return "check failed"
+    case failOther:
+        return "error"
+    }
+    return ""
+}
+
+// isError reports whether a failure says more about our side than about the
+// host: the name doesn't resolve or we aren't able to send at all. Such hosts
+// are shown as ERROR rather than DOWN.
+func (k failureKind) isError() bool {
+    return k == failDNS || k == failPermission || k == failExec
+}
+
+// failed returns the result of a probe that failed. reason is shown in the
+// REASON column and defaults to the failure kind's description.
+func failed(kind failureKind, reason, info string) pingResult {
+    if reason == "" {
+        reason = kind.String()
+    }
+    return pingResult{status: false, reply: -1, fail: kind, reason: reason, info: info}
+}
+
+// failedErr returns the result of a probe that failed with err.
+func failedErr(err error, info string) pingResult {
+    var dnsErr *net.DNSError
+    if errors.As(err, &dnsErr) && dnsErr.IsTimeout {
+        return failed(failDNS, "DNS timeout", info)
+    }
+    return failed(classifyError(err), "", info)
+}
+
+// classifyError maps a network error to a failure kind.
+func classifyError(err error) failureKind {
+    var ne net.Error
+    var dnsErr *net.DNSError
+    switch {
+    case errors.As(err, &dnsErr):
+        return failDNS
+    case errors.Is(err, syscall.ECONNREFUSED):
+        return failRefused
+    case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+        return failTimeout
+    case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+        return failUnreachable
+    case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+        return failPermission
+    }
+    return failOther
+}
+
+// probeKind identifies how a host is checked. It is derived from the scheme
+// of the target in hosts.txt; targets without a scheme are pinged.
+type probeKind int
+
+const (
+    probeICMP probeKind = iota
+    probeTCP
+    probeHTTP
+    probeDNS
+    probeTLS
+)
+
+// probeSchemes maps target URL schemes to probe kinds.
+var probeSchemes = map[string]probeKind{
+    "icmp":  probeICMP,
+    "tcp":   probeTCP,
+    "http":  probeHTTP,
+    "https": probeHTTP,
+    "dns":   probeDNS,
+    "tls":   probeTLS,
+}
+
+// parseTarget determines the probe kind for a target and validates the parts
+// that probe needs.
+func parseTarget(target string) (probeKind, error) {
+    if !strings.Contains(target, "://") {
+        return probeICMP, nil
+    }
+    u, err := url.Parse(target)
+    if err != nil {
+        return probeICMP, err
+    }
+    kind, ok := probeSchemes[strings.ToLower(u.Scheme)]
+    if !ok {
+        return probeICMP, fmt.Errorf("unknown probe type %q", u.Scheme)
+    }
+    if u.Hostname() == "" {
+        return probeICMP, fmt.Errorf("%s target has no host", u.Scheme)
+    }
+    if kind == probeTCP && u.Port() == "" {
+        return probeICMP, fmt.Errorf("tcp target needs a port, e.g. tcp://%s:443", u.Hostname())
+    }
+    if kind == probeDNS {
+        if _, err := parseDNSTarget(target); err != nil {
+            return probeICMP, err
+        }
+    }
+    return kind, nil
+}
+
+// pingHost runs one round against a host over its configured address family,
+// sending count probes unless the host overrides the count. Hosts set to
+// familyBoth are probed over IPv4 and IPv6 in parallel and both outcomes are
+// recorded in the result; the round statistics then cover both families.
+func pingHost(h Host, count int) pingResult {
+    if h.Count > 0 {
+        count = h.Count
+    }
+    if count < 1 {
+        count = 1
+    }
+    if h.Family != familyBoth {
+        res, _ := probeRound(h, h.Family, count)
+        return res.evaluate()
+    }
+    var v4, v6 pingResult
+    var rtts4, rtts6 []float64
+    var wg sync.WaitGroup
+    wg.Add(2)
+    go func() {
+        defer wg.Done()
+        v4, rtts4 = probeRound(h, family4, count)
+    }()
+    go func() {
+        defer wg.Done()
+        v6, rtts6 = probeRound(h, family6, count)
+    }()
+    wg.Wait()
+    res := v4
+    res.dual = true
+    res.stats = statsOf(append(rtts4, rtts6...), v4.stats.sent+v6.stats.sent, v4.stats.recv+v6.stats.recv)
+    res.status6, res.reply6, res.fail6, res.reason6 = v6.status, v6.reply, v6.fail, v6.reason
+    res.warn = v4.warn || v6.warn
+    if v6.info != "" {
+        res.info = "4:" + v4.info + " 6:" + v6.info
+    }
+    return res.evaluate()
+}
+
+// probeRound sends count probes to a host over a single address family,
+// spaced by packetGap, and waits for all of them. The host is up if any probe
+// succeeded; the reply time is then the average. Details such as INFO are
+// taken from the last successful probe, or the last probe if all failed. The
+// reply times of the successful probes are returned alongside. Pings that
+// can't use the built‑in ICMP engine run the system ping command once for
+// the whole round, which takes all count packets from the budget at once.
+func probeRound(h Host, fam addrFamily, count int) (pingResult, []float64) {
+    if h.Probe == probeICMP && !icmpAvailable(fam) {
+        pool.pace(count)
+        return pingHostExec(pingTarget(h), fam, count)
+    }
+    results := make([]pingResult, count)
+    var wg sync.WaitGroup
+    for i := 0; i < count; i++ {
+        if i > 0 {
+            time.Sleep(packetGap)
+        }
+        pool.pace(1)
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            results[i] = probeFamily(h, fam)
+        }(i)
+    }
+    wg.Wait()
+    res := results[count-1]
+    var rtts []float64
+    recv := 0
+    for _, r := range results {
+        if !r.status {
+            continue
+        }
+        recv++
+        res = r
+        if r.reply >= 0 {
+            rtts = append(rtts, r.reply)
+        }
+    }
+    res.stats = statsOf(rtts, count, recv)
+    res.reply = -1
+    if len(rtts) > 0 {
+        res.reply = res.stats.avg
+    }
+    return res, rtts
+}
+
+// probeFamily runs the host's probe once over a single address family.
+func probeFamily(h Host, fam addrFamily) pingResult {
+    switch h.Probe {
+    case probeTCP:
+        return probeTCPConnect(h.Host, fam)
+    case probeHTTP:
+        return probeHTTPGet(h, fam)
+    case probeDNS:
+        return probeDNSQuery(h.Host, fam)
+    case probeTLS:
+        return probeTLSHandshake(h, fam)
+    }
+    return pingAddr(pingTarget(h), fam)
+}
+
+// pingTarget returns the host name or address an ICMP host is pinged at.
+func pingTarget(h Host) string {
+    if u, err := url.Parse(h.Host); err == nil && u.Scheme != "" {
+        return u.Hostname()
+    }
+    return h.Host
+}
+
+// resolveFor looks up host restricted to the given address family and returns
+// the first address.
+func resolveFor(ctx context.Context, host string, fam addrFamily) (net.IP, error) {
+    network := "ip"
+    switch fam {
+    case family4:
+        network = "ip4"
+    case family6:
+        network = "ip6"
+    }
+    addrs, err := net.DefaultResolver.LookupIP(ctx, network, host)
+    if err != nil {
+        return nil, err
+    }
+    if len(addrs) == 0 {
+        return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+    }
+    return addrs[0], nil
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+    "fmt"
+    "maps"
+    "os"
+    "path/filepath"
+    "slices"
+    "sort"
+    "strings"
+    "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// Replay mode shows a recording from the store in the regular dashboard, as
+// it looked at the time: "mping replay mping-data/20260101.seg" replays one
+// day, "mping replay mping-data" everything kept. Nothing is probed; a replay
+// clock applies the recorded rounds instead and can be paused, sped up and
+// moved. The recorded states are shown as they were, without applying the
+// current thresholds again. Compacted segments only hold one summary per host
+// and minute, so their replay is coarser.
+
+// replaySpeeds lists the selectable playback speeds.
+var replaySpeeds = []int{1, 2, 5, 10, 30, 60, 300, 1800}
+
+// replayTick is how often the replay clock advances.
+const replayTick = 100 * time.Millisecond
+
+// Only the day being played is held in memory. While playing, the dashboard
+// state is saved about every replaySnapEvery, so that going back restarts
+// from the nearest snapshot instead of the beginning of the recording. Past
+// maxReplaySnapshots, every other snapshot is dropped and the spacing
+// doubles, which bounds their memory for long recordings.
+const (
+    replaySnapEvery    = time.Hour
+    maxReplaySnapshots = 48
+)
+
+// replay is the state of a replay session.
+type replay struct {
+    source   string
+    segs     []segment // segment files in day order
+    keys     []string  // host keys in the order first recorded
+    seg      int       // index of the segment in recs, -1 for none
+    recs     []record  // rounds of that segment in time order
+    next     int       // index of the first round in recs not applied yet
+    from, to time.Time
+    at       time.Time // replay clock
+    playing  bool
+    speed    int // index into replaySpeeds
+    ids      map[string]int
+    lastTick time.Time
+
+    snaps     []replaySnapshot // in time order
+    snapEvery time.Duration
+}
+
+// replaySnapshot is the dashboard state saved before the round at the
+// given position of the recording was applied.
+type replaySnapshot struct {
+    at            time.Time
+    seg, next     int
+    results       map[int]pingResult
+    history       map[int]*history
+    uptime        map[int]*uptime
+    incidents     []incident
+    openIncidents map[string]int
+    message       string
+}
+
+// replayTickMsg advances the replay clock.
+type replayTickMsg time.Time
+
+// replayTickCmd schedules the next replayTickMsg.
+func replayTickCmd() tea.Cmd {
+    return tea.Tick(replayTick, func(t time.Time) tea.Msg { return replayTickMsg(t) })
+}
+
+// now returns the time the dashboard shows: the replay clock in replay mode,
+// otherwise the current time.
+func (m model) now() time.Time {
+    if m.replay != nil {
+        return m.replay.at
+    }
+    return time.Now()
+}
+
+// replaySegments lists the segment files of path, a segment file or a data
+// directory.
+func replaySegments(path string) ([]segment, error) {
+    fi, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+    if fi.IsDir() {
+        return segments(path)
+    }
+    ext := filepath.Ext(path)
+    seg := segment{path: path, compacted: ext == ".cseg"}
+    if day, err := time.Parse("20060102", strings.TrimSuffix(filepath.Base(path), ext)); err == nil {
+        seg.day = day
+    }
+    return []segment{seg}, nil
+}
+
+// readRounds calls fn for each round recorded in seg until fn returns
+// false. Compacted summaries are turned into one round per minute.
+func readRounds(seg segment, fn func(record) bool) error {
+    read := func(r record) bool {
+        switch r.kind {
+        case recRound:
+            return fn(r)
+        case recAggregate:
+            if r.rounds == 0 {
+                break
+            }
+            round := record{kind: recRound, at: r.at, key: r.key, state: stateDown,
+                sent: r.sent, recv: r.recv, rtt: -1}
+            if 2*r.up >= r.rounds {
+                round.state = stateUp
+            }
+            if r.timed > 0 {
+                round.rtt = r.rttSum / float64(r.timed)
+            }
+            return fn(round)
+        }
+        return true
+    }
+    err := readSegment(seg.path, read)
+    if os.IsNotExist(err) && !seg.compacted && filepath.Ext(seg.path) == ".seg" {
+        // Compacted since it was listed
+        err = readSegment(strings.TrimSuffix(seg.path, ".seg")+".cseg", read)
+    }
+    return err
+}
+
+// loadReplay opens the recording in path, a segment file or a data
+// directory. It reads it through once for the hosts and the time span
+// covered; the rounds themselves are read a day at a time while playing.
+func loadReplay(path string) (*replay, error) {
+    segs, err := replaySegments(path)
+    if err != nil {
+        return nil, err
+    }
+    rp := &replay{source: path, segs: segs, seg: -1, playing: true,
+        ids: make(map[string]int), snapEvery: replaySnapEvery}
+    seen := make(map[string]bool)
+    for _, seg := range segs {
+        err := readRounds(seg, func(r record) bool {
+            if !seen[r.key] {
+                seen[r.key] = true
+                rp.keys = append(rp.keys, r.key)
+            }
+            if rp.from.IsZero() || r.at.Before(rp.from) {
+                rp.from = r.at
+            }
+            if r.at.After(rp.to) {
+                rp.to = r.at
+            }
+            return true
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    if len(rp.keys) == 0 {
+        return nil, fmt.Errorf("%s holds no recorded rounds", path)
+    }
+    rp.at = rp.from
+    return rp, nil
+}
+
+// replayHosts returns the hosts of a replay, with the descriptions of the
+// matching hosts.txt entries where there are any, and the keys they were
+// recorded under.
+func replayHosts(rp *replay, known []Host) ([]Host, []string) {
+    desc := make(map[string]string, len(known))
+    for _, h := range known {
+        desc[h.spec()] = h.Desc
+    }
+    hosts := make([]Host, len(rp.keys))
+    for k, key := range rp.keys {
+        h, err := parseHostSpec(key)
+        if err != nil {
+            h = Host{Host: strings.Fields(key)[0]}
+        }
+        h.Desc = desc[key]
+        hosts[k] = h
+    }
+    return hosts, rp.keys
+}
+
+// newReplayModel returns a model showing the replay.
+func newReplayModel(rp *replay, known []Host) model {
+    hosts, keys := replayHosts(rp, known)
+    m := model{
+        hosts:      hosts,
+        results:    make(map[int]pingResult),
+        history:    make(map[int]*history),
+        uptime:     make(map[int]*uptime),
+        interval:   5 * time.Second,
+        count:      1,
+        workers:    defaultWorkers,
+        fall:       1,
+        rise:       1,
+        statWindow: defaultStatWindow,
+        mode:       modeList,
+        sortBy:     "name",
+        replay:     rp,
+    }
+    m.assignIDs(m.hosts)
+    for k, h := range m.hosts {
+        rp.ids[keys[k]] = h.ID
+    }
+    m.sortHosts()
+    m.seek(rp.from)
+    return m
+}
+
+// seek moves the replay clock to t and brings the dashboard to the state it
+// had then. Going back starts over from the latest snapshot taken by then.
+func (m *model) seek(t time.Time) {
+    rp := m.replay
+    if t.Before(rp.from) {
+        t = rp.from
+    }
+    if t.After(rp.to) {
+        t = rp.to
+    }
+    if t.Before(rp.at) {
+        m.rewind(t)
+    }
+    rp.at = t
+    for {
+        if rp.next == len(rp.recs) {
+            if rp.seg+1 >= len(rp.segs) {
+                return
+            }
+            m.loadSegment(rp.seg + 1)
+            continue
+        }
+        r := rp.recs[rp.next]
+        if r.at.After(t) {
+            return
+        }
+        m.snapshot(r.at)
+        m.replayRound(r)
+        rp.next++
+    }
+}
+
+// loadSegment reads the rounds of the k‑th segment for playing, replacing
+// the previous one. A segment that can't be read is skipped.
+func (m *model) loadSegment(k int) {
+    rp := m.replay
+    rp.seg, rp.recs, rp.next = k, nil, 0
+    err := readRounds(rp.segs[k], func(r record) bool {
+        rp.recs = append(rp.recs, r)
+        return true
+    })
+    if err != nil {
+        m.setMessage(fmt.Sprintf("Failed to read %s: %v", filepath.Base(rp.segs[k].path), err))
+    }
+    sort.SliceStable(rp.recs, func(i, j int) bool { return rp.recs[i].at.Before(rp.recs[j].at) })
+}
+
+// snapshot saves the dashboard state before the round at t is applied, if
+// t starts a new snapEvery period since the last snapshot.
+func (m *model) snapshot(t time.Time) {
+    rp := m.replay
+    last := rp.from
+    if n := len(rp.snaps); n > 0 {
+        last = rp.snaps[n-1].at
+    }
+    if t.Before(last.Truncate(rp.snapEvery).Add(rp.snapEvery)) {
+        return
+    }
+    rp.snaps = append(rp.snaps, replaySnapshot{
+        at:            t,
+        seg:           rp.seg,
+        next:          rp.next,
+        results:       maps.Clone(m.results),
+        history:       cloneHistories(m.history),
+        uptime:        cloneUptimes(m.uptime),
+        incidents:     slices.Clone(m.incidents),
+        openIncidents: maps.Clone(m.openIncidents),
+        message:       m.message,
+    })
+    if len(rp.snaps) > maxReplaySnapshots {
+        n := 0
+        for i := 0; i < len(rp.snaps); i += 2 {
+            rp.snaps[n] = rp.snaps[i]
+            n++
+        }
+        clear(rp.snaps[n:])
+        rp.snaps = rp.snaps[:n]
+        rp.snapEvery *= 2
+    }
+}
+
+// rewind brings the dashboard back to the latest snapshot taken at or
+// before t, or to the beginning of the recording if there is none.
+func (m *model) rewind(t time.Time) {
+    rp := m.replay
+    k := sort.Search(len(rp.snaps), func(i int) bool { return rp.snaps[i].at.After(t) }) - 1
+    if k < 0 {
+        m.results = make(map[int]pingResult)
+        m.history = make(map[int]*history)
+        m.uptime = make(map[int]*uptime)
+        m.incidents, m.openIncidents = nil, nil
+        m.setMessage("")
+        rp.seg, rp.recs, rp.next = -1, nil, 0
+        return
+    }
+    // The snapshot is copied again, as it may be restored more than once.
+    s := rp.snaps[k]
+    m.results = maps.Clone(s.results)
+    m.history = cloneHistories(s.history)
+    m.uptime = cloneUptimes(s.uptime)
+    m.incidents = slices.Clone(s.incidents)
+    m.openIncidents = maps.Clone(s.openIncidents)
+    m.setMessage(s.message)
+    if rp.seg != s.seg {
+        m.loadSegment(s.seg)
+    }
+    rp.next = s.next
+}
+
+// cloneHistories returns a deep copy of hist.
+func cloneHistories(hist map[int]*history) map[int]*history {
+    c := make(map[int]*history, len(hist))
+    for id, h := range hist {
+        c[id] = h.clone()
+    }
+    return c
+}
+
+// cloneUptimes returns a deep copy of up.
+func cloneUptimes(up map[int]*uptime) map[int]*uptime {
+    c := make(map[int]*uptime, len(up))
+    for id, u := range up {
+        v := *u
+        c[id] = &v
+    }
+    return c
+}
+
+// replayRound applies a recorded round like applyResult applies a live one.
+func (m *model) replayRound(r record) {
+    id, ok := m.replay.ids[r.key]
+    if !ok {
+        return
+    }
+    i := m.hostIndex(id)
+    if i < 0 {
+        return
+    }
+    prev := m.results[id]
+    res := pingResult{state: r.state, status: r.state == stateUp || r.state == stateDegraded,
+        reply: -1, reason: r.reason}
+    // Records keep the state and reason only; the reason tells critical
+    // latency
+    res.critical = res.up() && strings.Contains(r.reason, "critical latency")
+    res.stats = roundStats{sent: r.sent, recv: r.recv}
+    if r.rtt >= 0 {
+        res.reply = r.rtt
+        res.stats.timed = 1
+        res.stats.min, res.stats.avg, res.stats.max = r.rtt, r.rtt, r.rtt
+    }
+    m.historyFor(m.hosts[i]).add(sample{at: r.at.UnixMilli(), rtt: float32(r.rtt),
+        sent: uint8(min(r.sent, 255)), recv: uint8(min(r.recv, 255))})
+    res.uptime = m.countUptime(id, res, r.at)
+    changed := prev.state != statePending && prev.state != res.state
+    res.lastChange = prev.lastChange
+    res.trackFlapping(prev, changed, r.at)
+    switch {
+    case prev.state == statePending:
+        res.lastChange = r.at
+    case changed:
+        res.lastChange = r.at
+        if !res.flapping {
+            res.flashUntil = r.at.Add(2 * time.Second)
+            m.setMessage(r.at.Format("15:04:05") + " " + res.describe(m.hosts[i].Host))
+        }
+    default:
+        res.flashUntil = prev.flashUntil
+    }
+    m.noteState(r.key, prev.state, res.state, changed, res.reasonOnly(), r.at)
+    m.results[id] = res
+}
+
+// scan calls fn for the recorded rounds in [from, to) up to the replay
+// clock, like store.scan, reading them from the recording again.
+func (rp *replay) scan(from, to time.Time, fn func(record) bool) error {
+    if end := rp.at.Add(time.Millisecond); end.Before(to) {
+        to = end
+    }
+    stop := false
+    for _, seg := range rp.segs {
+        if !seg.day.IsZero() && (!seg.day.Add(24*time.Hour).After(from) || !seg.day.Before(to)) {
+            continue
+        }
+        err := readRounds(seg, func(r record) bool {
+            if r.at.Before(from) || !r.at.Before(to) {
+                return true
+            }
+            if !fn(r) {
+                stop = true
+                return false
+            }
+            return true
+        })
+        if err != nil || stop {
+            return err
+        }
+    }
+    return nil
+}
+
+// advanceReplay moves the replay clock on by the time passed since the last
+// tick, times the playback speed. Playback stops at the end.
+func (m *model) advanceReplay(t time.Time) {
+    rp := m.replay
+    elapsed := t.Sub(rp.lastTick)
+    rp.lastTick = t
+    if !rp.playing || elapsed <= 0 || elapsed > time.Second {
+        return
+    }
+    m.seek(rp.at.Add(elapsed * time.Duration(replaySpeeds[rp.speed])))
+    if !rp.at.Before(rp.to) {
+        rp.playing = false
+    }
+}
+
+// updateReplayKey handles the playback keys of the list screen in replay
+// mode. Keys that would change the host list or hosts.txt are refused. It
+// reports whether it handled the key.
+func (m *model) updateReplayKey(key string) bool {
+    rp := m.replay
+    switch key {
+    case " ", "p", "P":
+        if !rp.playing && !rp.at.Before(rp.to) {
+            m.seek(rp.from)
+        }
+        rp.playing = !rp.playing
+    case "+", "=":
+        rp.speed = min(rp.speed+1, len(replaySpeeds)-1)
+    case "-", "_":
+        rp.speed = max(rp.speed-1, 0)
+    case "left":
+        m.seek(rp.at.Add(-time.Minute))
+    case "right":
+        m.seek(rp.at.Add(time.Minute))
+    case "[":
+        m.seek(rp.at.Add(-10 * time.Minute))
+    case "]":
+        m.seek(rp.at.Add(10 * time.Minute))
+    case "{":
+        m.seek(rp.at.Add(-time.Hour))
+    case "}":
+        m.seek(rp.at.Add(time.Hour))
+    case "home":
+        m.seek(rp.from)
+    case "end":
+        m.seek(rp.to)
+    case "a", "A", "e", "E", "d", "D", "s", "S", "r", "R":
+        m.setMessage("Not available in replay")
+    default:
+        return false
+    }
+    return true
+}
+
+// replayLine renders the status line of replay mode: the replay clock, the
+// playback state and a progress bar over the recording.
+func (m model) replayLine(width int) string {
+    rp := m.replay
+    state := "▶"
+    if !rp.playing {
+        state = "⏸"
+    }
+    layout := "2006-01-02 15:04:05"
+    text := fmt.Sprintf("REPLAY %s %dx   %s", state, replaySpeeds[rp.speed], rp.at.Format(layout))
+    ends := fmt.Sprintf("%s – %s", rp.from.Format(layout), rp.to.Format(layout))
+    bar := max(min(width-len([]rune(text))-len([]rune(ends))-8, 40), 10)
+    done := 0
+    if span := rp.to.Sub(rp.from); span > 0 {
+        done = int(float64(bar) * float64(rp.at.Sub(rp.from)) / float64(span))
+    }
+    done = min(max(done, 0), bar)
+    return text + "   [" + strings.Repeat("=", done) + strings.Repeat("-", bar-done) + "]   " + ends
+}
+
+// runReplay implements "mping replay <file>".
+func runReplay(args []string) {
+    if len(args) != 1 {
+        fmt.Fprintln(os.Stderr, "usage: mping replay <segment file or data directory>")
+        os.Exit(2)
+    }
+    rp, err := loadReplay(args[0])
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load recording: %v\n", err)
+        os.Exit(1)
+    }
+    known, _ := loadHostsFromFile("hosts.txt")
+    p := tea.NewProgram(newReplayModel(rp, known), tea.WithAltScreen())
+    if _, err := p.Run(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
+        os.Exit(1)
+    }
+}
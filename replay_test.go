@@ -0,0 +1,97 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+// writeRecording writes two days of rounds to dir, one every ten minutes for
+// two hosts, with an outage of host a in the afternoon of each day.
+func writeRecording(t *testing.T, dir string) {
+    t.Helper()
+    for _, day := range []string{"20260101", "20260102"} {
+        var lines []string
+        for ms := int64(0); ms < 24*3600*1000; ms += 10 * 60 * 1000 {
+            a := record{kind: recRound, at: at(day, ms), key: "a", state: stateUp, sent: 1, recv: 1, rtt: float64(ms%7 + 1)}
+            if h := ms / 3600_000; h >= 14 && h < 16 {
+                a.state, a.recv, a.rtt, a.reason = stateDown, 0, -1, "timeout"
+            }
+            b := record{kind: recRound, at: at(day, ms+1000), key: "b", state: stateUp, sent: 1, recv: 1, rtt: 3}
+            lines = append(lines, a.encode(), b.encode())
+        }
+        writeSegment(t, dir, day+".seg", lines...)
+    }
+}
+
+// replayState returns the parts of m's dashboard a seek must restore.
+func replayState(m model) []any {
+    hist := make(map[int][]sample)
+    for id, h := range m.history {
+        hist[id] = h.last(h.len())
+    }
+    return []any{m.results, hist, m.uptime, m.incidents, m.openIncidents, m.message}
+}
+
+func TestReplaySeekBack(t *testing.T) {
+    dir := t.TempDir()
+    writeRecording(t, dir)
+    rp, err := loadReplay(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(rp.keys) != 2 || !rp.from.Equal(at("20260101", 0)) || !rp.to.Equal(at("20260102", 143*600_000+1000)) {
+        t.Fatalf("loaded keys %v from %v to %v", rp.keys, rp.from, rp.to)
+    }
+    m := newReplayModel(rp, nil)
+    m.seek(rp.to)
+    if rp.seg != 1 || len(rp.recs) != 2*144 {
+        t.Errorf("segment %d with %d rounds in memory, want only the second day's", rp.seg, len(rp.recs))
+    }
+    if len(rp.snaps) > maxReplaySnapshots || len(rp.snaps) < maxReplaySnapshots/2 {
+        t.Errorf("%d snapshots kept, want at most %d", len(rp.snaps), maxReplaySnapshots)
+    }
+
+    for _, to := range []time.Time{
+        at("20260102", 15*3600_000+5*60_000), // during the second outage
+        at("20260101", 20*3600_000),          // back to the first day
+        at("20260101", 15*60_000),            // before the first snapshot
+    } {
+        m.seek(to)
+        fresh, err := loadReplay(dir)
+        if err != nil {
+            t.Fatal(err)
+        }
+        want := newReplayModel(fresh, nil)
+        want.seek(to)
+        if got, want := replayState(m), replayState(want); !reflect.DeepEqual(got, want) {
+            t.Errorf("after seeking back to %v:\n  %+v\nwant\n  %+v", to, got, want)
+        }
+    }
+}
+
+func TestReplayScan(t *testing.T) {
+    dir := t.TempDir()
+    writeRecording(t, dir)
+    rp, err := loadReplay(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    m := newReplayModel(rp, nil)
+    m.seek(at("20260102", 3600_000))
+    n := 0
+    err = rp.scan(at("20260101", 23*3600_000), rp.to, func(r record) bool {
+        if r.at.After(rp.at) {
+            t.Errorf("scanned round at %v, after the replay clock", r.at)
+        }
+        n++
+        return true
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    // An hour on each side of midnight, plus the round of a at 01:00
+    if n != 2*12+1 {
+        t.Errorf("scanned %d rounds, want %d", n, 2*12+1)
+    }
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "math/rand"
+    "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// Every host is probed on its own timer: a hostTickMsg starts a probe, the
+// probe answers with a hostResultMsg, and handling that result arms the next
+// tick for the same host. A slow or hanging host therefore only delays its
+// own row.
+//
+// Messages carry the schedule generation they were created for and address
+// hosts by their stable ID. Whenever the host list or the interval changes
+// the generation is bumped and a fresh set of timers is started, so timers
+// belonging to an older generation simply die out when they fire. Results
+// additionally carry a round number so that an older round finishing after
+// a newer one can't overwrite it.
+
+// hostTickMsg signals it's time to probe the host with the given ID again.
+type hostTickMsg struct {
+    gen int
+    id  int
+}
+
+// hostResultMsg delivers the outcome of one round against a single host.
+// took is the time from queueing the round until it finished.
+type hostResultMsg struct {
+    gen  int
+    id   int
+    res  pingResult
+    took time.Duration
+}
+
+// intervalFor returns the probe interval for h: its own interval option if
+// set, otherwise the global interval.
+func (m model) intervalFor(h Host) time.Duration {
+    if h.Interval > 0 {
+        return h.Interval
+    }
+    return m.interval
+}
+
+// scheduleCmd starts the timers for all hosts under the current generation.
+// Hosts with a jitter option start after a random delay of up to that
+// amount, spreading their probes apart; all others are probed immediately.
+func (m model) scheduleCmd() tea.Cmd {
+    cmds := make([]tea.Cmd, 0, len(m.hosts))
+    for _, h := range m.hosts {
+        var delay time.Duration
+        if h.Jitter > 0 {
+            delay = time.Duration(rand.Int63n(int64(h.Jitter)))
+        }
+        cmds = append(cmds, hostTickCmd(m.schedGen, h.ID, delay))
+    }
+    return tea.Batch(cmds...)
+}
+
+// hostTickCmd returns a command that sends a hostTickMsg for the host with
+// the given ID after delay, or straight away if delay is zero.
+func hostTickCmd(gen, id int, delay time.Duration) tea.Cmd {
+    if delay <= 0 {
+        return func() tea.Msg { return hostTickMsg{gen: gen, id: id} }
+    }
+    return tea.Tick(delay, func(time.Time) tea.Msg {
+        return hostTickMsg{gen: gen, id: id}
+    })
+}
+
+// probeCmd returns a command that runs round number round against h, sending
+// count packets, and reports the outcome as a hostResultMsg. The round waits
+// for a worker of the shared probe pool first.
+func probeCmd(gen int, round uint64, h Host, count int) tea.Cmd {
+    return func() tea.Msg {
+        queued := time.Now()
+        pool.acquire()
+        res := pingHost(h, count)
+        pool.release()
+        res.round = round
+        return hostResultMsg{gen: gen, id: h.ID, res: res, took: time.Since(queued)}
+    }
+}
+
+// hostIndex returns the index of the host with the given ID, or -1.
+func (m model) hostIndex(id int) int {
+    for i, h := range m.hosts {
+        if h.ID == id {
+            return i
+        }
+    }
+    return -1
+}
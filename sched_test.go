@@ -0,0 +1,244 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestModel returns a model set up the way main does, listing the given
+// host specs.
+func newTestModel(t *testing.T, specs ...string) model {
+    t.Helper()
+    m := model{
+        results:    make(map[int]pingResult),
+        history:    make(map[int]*history),
+        uptime:     make(map[int]*uptime),
+        interval:   5 * time.Second,
+        count:      1,
+        workers:    defaultWorkers,
+        fall:       1,
+        rise:       1,
+        statWindow: defaultStatWindow,
+        mode:       modeList,
+        sortBy:     "name",
+    }
+    for _, spec := range specs {
+        h, err := parseHostSpec(spec)
+        if err != nil {
+            t.Fatalf("parseHostSpec(%q): %v", spec, err)
+        }
+        m.hosts = append(m.hosts, h)
+    }
+    m.assignIDs(m.hosts)
+    m.sortHosts()
+    return m
+}
+
+// keyMsg returns a key press as Bubble Tea delivers it.
+func keyMsg(k string) tea.KeyMsg {
+    switch k {
+    case "enter":
+        return tea.KeyMsg{Type: tea.KeyEnter}
+    case "tab":
+        return tea.KeyMsg{Type: tea.KeyTab}
+    case "down":
+        return tea.KeyMsg{Type: tea.KeyDown}
+    }
+    return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+// send feeds msgs to m in order. It returns the resulting model and the
+// command returned for the last message.
+func send(m model, msgs ...tea.Msg) (model, tea.Cmd) {
+    var cmd tea.Cmd
+    for _, msg := range msgs {
+        var next tea.Model
+        next, cmd = m.Update(msg)
+        m = next.(model)
+    }
+    return m, cmd
+}
+
+// keys returns the messages of a sequence of key presses.
+func keys(ks ...string) []tea.Msg {
+    msgs := make([]tea.Msg, len(ks))
+    for i, k := range ks {
+        msgs[i] = keyMsg(k)
+    }
+    return msgs
+}
+
+// upResult returns the message of an answered round against the host with
+// the given ID.
+func upResult(gen, id int, round uint64, rtt float64) hostResultMsg {
+    res := pingResult{status: true, reply: rtt, round: round,
+        stats: roundStats{sent: 1, recv: 1, timed: 1, min: rtt, avg: rtt, max: rtt}}
+    return hostResultMsg{gen: gen, id: id, res: res.evaluate()}
+}
+
+// downResult returns the message of a round against the host with the
+// given ID that nothing answered.
+func downResult(gen, id int, round uint64) hostResultMsg {
+    res := pingResult{reason: "timeout", reply: -1, round: round, stats: roundStats{sent: 1}}
+    return hostResultMsg{gen: gen, id: id, res: res.evaluate()}
+}
+
+// idOf returns the ID of the listed host whose target is host, or -1.
+func idOf(m model, host string) int {
+    for _, h := range m.hosts {
+        if h.Host == host {
+            return h.ID
+        }
+    }
+    return -1
+}
+
+// onlyResults fails the test unless exactly the hosts with the given IDs
+// have a result.
+func onlyResults(t *testing.T, m model, ids ...int) {
+    t.Helper()
+    if len(m.results) != len(ids) {
+        t.Errorf("%d results, want %d: %+v", len(m.results), len(ids), m.results)
+    }
+    for _, id := range ids {
+        if _, ok := m.results[id]; !ok {
+            t.Errorf("no result for host %d", id)
+        }
+    }
+}
+
+func TestResultAfterDelete(t *testing.T) {
+    m := newTestModel(t, "a.example", "b.example", "c.example")
+    b := idOf(m, "b.example")
+    m, _ = send(m, keys("down", "d", "y")...)
+    if idOf(m, "b.example") != -1 {
+        t.Fatal("b.example wasn't deleted")
+    }
+    m, cmd := send(m, upResult(m.schedGen, b, 1, 10))
+    onlyResults(t, m)
+    if cmd != nil {
+        t.Error("the deleted host's result armed another tick")
+    }
+}
+
+func TestResultAfterEdit(t *testing.T) {
+    m := newTestModel(t, "a.example", "b.example")
+    a := idOf(m, "a.example")
+
+    // Changing the host column gives the host a new identity; the round in
+    // flight for the old one is dropped.
+    edited, _ := send(m, keys("e", " fall=2", "enter", "enter")...)
+    if id := idOf(edited, "a.example"); id == a || id < 0 {
+        t.Fatalf("edited host has ID %d, want a new one instead of %d", id, a)
+    }
+    edited, cmd := send(edited, upResult(m.schedGen, a, 1, 10))
+    onlyResults(t, edited)
+    if cmd != nil {
+        t.Error("the replaced host's result armed another tick")
+    }
+
+    // Changing only the description keeps it, and the result lands. The
+    // edit above changed the host list m shares, so start over.
+    m = newTestModel(t, "a.example", "b.example")
+    described, _ := send(m, keys("e", "tab", "router", "enter")...)
+    if idOf(described, "a.example") != a || described.hosts[0].Desc != "router" {
+        t.Fatalf("described host = %+v, want ID %d with the new description", described.hosts[0], a)
+    }
+    described, cmd = send(described, upResult(m.schedGen, a, 1, 10))
+    onlyResults(t, described, a)
+    if cmd == nil {
+        t.Error("the host's result didn't arm its next tick")
+    }
+}
+
+func TestResultAfterReload(t *testing.T) {
+    dir := t.TempDir()
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.Chdir(wd) })
+
+    m := newTestModel(t, "a.example", "b.example", "c.example")
+    a, b, c := idOf(m, "a.example"), idOf(m, "b.example"), idOf(m, "c.example")
+    m, _ = send(m, upResult(m.schedGen, b, 1, 5))
+    // Counts loaded from the store come without a result or history
+    m.uptime[c] = &uptime{}
+    // a stays, b changes its options, c goes and d is new
+    hosts := "a.example,kept\nb.example fall=3,changed\nd.example,new\n"
+    if err := os.WriteFile(filepath.Join(dir, "hosts.txt"), []byte(hosts), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    m, _ = send(m, keyMsg("r"))
+    if len(m.hosts) != 3 || idOf(m, "a.example") != a {
+        t.Fatalf("reloaded hosts %+v, want three with a.example keeping ID %d", m.hosts, a)
+    }
+    // The hosts that went or changed leave nothing behind
+    for _, id := range []int{b, c} {
+        if m.history[id] != nil || m.uptime[id] != nil {
+            t.Errorf("host %d kept its history or uptime counts", id)
+        }
+    }
+    for _, id := range []int{a, b, c} {
+        m, _ = send(m, upResult(m.schedGen, id, 2, 10))
+    }
+    onlyResults(t, m, a)
+    if m.results[a].reply != 10 {
+        t.Errorf("a.example reply = %v, want 10", m.results[a].reply)
+    }
+}
+
+func TestResultAfterResort(t *testing.T) {
+    m := newTestModel(t, "a.example", "b.example", "c.example")
+    a, c := idOf(m, "a.example"), idOf(m, "c.example")
+    m, _ = send(m, upResult(m.schedGen, c, 1, 10))
+    gen := m.schedGen
+
+    // Sort by status: the host that is UP moves to the top, and the
+    // schedule starts over.
+    msgs := keys("o")
+    for range m.optInputs() {
+        msgs = append(msgs, keyMsg("enter"))
+    }
+    msgs = append(msgs, keys("down", "down", "enter")...)
+    m, _ = send(m, msgs...)
+    if m.sortBy != "status" || m.hosts[0].ID != c || m.schedGen == gen {
+        t.Fatalf("sorted by %q with %s first, generation %d; want status, c.example and a new generation",
+            m.sortBy, m.hosts[0].Host, m.schedGen)
+    }
+
+    // A round of the old schedule still lands on its host, but leaves
+    // arming the next tick to the new schedule.
+    m, cmd := send(m, upResult(gen, a, 2, 20))
+    onlyResults(t, m, a, c)
+    if m.results[a].reply != 20 || m.results[c].reply != 10 {
+        t.Errorf("replies a %v, c %v; want 20 and 10", m.results[a].reply, m.results[c].reply)
+    }
+    if cmd != nil {
+        t.Error("a result of the old generation armed a tick")
+    }
+    if _, cmd = send(m, upResult(m.schedGen, a, 3, 30)); cmd == nil {
+        t.Error("a result of the current generation didn't arm the next tick")
+    }
+}
+
+func TestResultOfStaleRound(t *testing.T) {
+    m := newTestModel(t, "a.example")
+    a := idOf(m, "a.example")
+    m, _ = send(m, upResult(m.schedGen, a, 5, 50))
+    // Round 3 was overtaken by round 5, e.g. after a reschedule
+    m, _ = send(m, upResult(m.schedGen, a, 3, 30))
+    if res := m.results[a]; res.round != 5 || res.reply != 50 {
+        t.Errorf("result of round %d with reply %v, want round 5 with 50", res.round, res.reply)
+    }
+    if n := m.history[a].len(); n != 1 {
+        t.Errorf("%d rounds in the history, want 1", n)
+    }
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+    "fmt"
+    "time"
+)
+
+// A host's state only flips between reachable (UP or DEGRADED) and
+// unreachable (DOWN or ERROR) after enough consecutive rounds agree: fall
+// failed rounds take an UP host down, rise successful rounds bring a DOWN
+// host back up. Until then the host keeps its previous state and is shown
+// as SUSPECT, so a single transient blip neither rings the bell nor resets
+// the last change time. Changes that don't cross that line, such as UP to
+// DEGRADED, apply straight away.
+
+// A reachable host whose reply time reaches the warning or the critical
+// latency is DEGRADED, with a reason naming the threshold it reached. A slow
+// host still answers, so neither makes it DOWN. Reaching the critical
+// latency also shows the host as CRITICAL in its own colour, sorts it right
+// before the hosts that are down and rings the bell, even for a host that
+// was DEGRADED already. Both are off unless set globally or per host.
+
+// A host whose state keeps changing is flapping: flapStart or more changes
+// within flapWindow mark it FLAPPING, and it stays so until fewer than
+// flapStop changes remain in the window. While flapping, state changes are
+// still recorded but neither ring the bell nor highlight the row.
+const (
+    flapWindow = 5 * time.Minute
+    flapStart  = 6
+    flapStop   = 3
+)
+
+// maxThreshold bounds the fall and rise settings.
+const maxThreshold = 20
+
+// thresholdsFor returns the fall and rise thresholds for h: its own options
+// where set, otherwise the global settings.
+func (m model) thresholdsFor(h Host) (fall, rise int) {
+    fall, rise = m.fall, m.rise
+    if h.Fall > 0 {
+        fall = h.Fall
+    }
+    if h.Rise > 0 {
+        rise = h.Rise
+    }
+    return max(fall, 1), max(rise, 1)
+}
+
+// latencyFor returns the warning and critical latency for h: its own options
+// where set, otherwise the global settings. Zero disables a threshold.
+func (m model) latencyFor(h Host) (warn, crit time.Duration) {
+    warn, crit = m.latWarn, m.latCrit
+    if h.Warn > 0 {
+        warn = h.Warn
+    }
+    if h.Crit > 0 {
+        crit = h.Crit
+    }
+    return warn, crit
+}
+
+// rateLatency applies the host's latency thresholds to the result of a
+// finished round. Dual‑stack hosts are rated by the slower family.
+func (m model) rateLatency(h Host, res pingResult) pingResult {
+    if !res.up() {
+        return res
+    }
+    warn, crit := m.latencyFor(h)
+    ms := res.reply
+    if res.dual && res.reply6 > ms {
+        ms = res.reply6
+    }
+    if ms < 0 {
+        return res
+    }
+    var text string
+    switch {
+    case crit > 0 && ms >= float64(crit.Microseconds())/1000:
+        text = fmt.Sprintf("critical latency %.0f ms >= %d ms", ms, crit.Milliseconds())
+        res.critical = true
+    case warn > 0 && ms >= float64(warn.Microseconds())/1000:
+        text = fmt.Sprintf("latency %.0f ms >= %d ms", ms, warn.Milliseconds())
+    default:
+        return res
+    }
+    res.state = stateDegraded
+    if res.reason != "" {
+        res.reason += ", " + text
+    } else {
+        res.reason = text
+    }
+    return res
+}
+
+// settle applies the host's thresholds to the result of a finished round.
+// prev is the result currently shown. If res would flip the host between
+// reachable and unreachable before the threshold is met, the previous state
+// is kept and the result is marked suspect; everything else about res, such
+// as its reply time and reason, is shown as measured.
+func (m model) settle(h Host, prev, res pingResult) pingResult {
+    if prev.state == statePending || res.up() == prev.up() {
+        return res
+    }
+    fall, rise := m.thresholdsFor(h)
+    need := fall
+    if res.up() {
+        need = rise
+    }
+    res.streak = prev.streak + 1
+    if res.streak >= need {
+        res.streak = 0
+        return res
+    }
+    res.state = prev.state
+    res.suspect = true
+    return res
+}
+
+// trackFlapping carries the recent state changes over from prev, records a
+// new one at now if changed is set, and updates the flapping flag.
+func (r *pingResult) trackFlapping(prev pingResult, changed bool, now time.Time) {
+    r.changes = r.changes[:0:0]
+    for _, t := range prev.changes {
+        if now.Sub(t) < flapWindow {
+            r.changes = append(r.changes, t)
+        }
+    }
+    if changed {
+        r.changes = append(r.changes, now)
+    }
+    switch {
+    case len(r.changes) >= flapStart:
+        r.flapping = true
+    case len(r.changes) < flapStop:
+        r.flapping = false
+    default:
+        r.flapping = prev.flapping
+    }
+}
+
+// flapText describes the recent state changes, e.g. "7 changes/5m".
+func (r pingResult) flapText() string {
+    return fmt.Sprintf("%d changes/%.0fm", len(r.changes), flapWindow.Minutes())
+}
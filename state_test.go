@@ -0,0 +1,216 @@
+package main
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRateLatency(t *testing.T) {
+    up := func(reply float64) pingResult { return pingResult{state: stateUp, status: true, reply: reply} }
+    tests := []struct {
+        name       string
+        spec       string
+        warn, crit time.Duration // the global settings
+        res        pingResult
+        state      hostState
+        reason     string
+        critical   bool
+    }{
+        {"below warn", "db01", 100 * time.Millisecond, 500 * time.Millisecond, up(50), stateUp, "", false},
+        {"at warn", "db01", 100 * time.Millisecond, 500 * time.Millisecond, up(100), stateDegraded, "latency 100 ms >= 100 ms", false},
+        {"above warn", "db01", 100 * time.Millisecond, 500 * time.Millisecond, up(150), stateDegraded, "latency 150 ms >= 100 ms", false},
+        {"at crit", "db01", 100 * time.Millisecond, 500 * time.Millisecond, up(500), stateDegraded, "critical latency 500 ms >= 500 ms", true},
+        {"crit without warn", "db01", 0, 500 * time.Millisecond, up(900), stateDegraded, "critical latency 900 ms >= 500 ms", true},
+        {"both off", "db01", 0, 0, up(5000), stateUp, "", false},
+        {"host warn", "db01 warn=20", 100 * time.Millisecond, 500 * time.Millisecond, up(50), stateDegraded, "latency 50 ms >= 20 ms", false},
+        {"host crit", "db01 crit=1s", 100 * time.Millisecond, 500 * time.Millisecond, up(600), stateDegraded, "latency 600 ms >= 100 ms", false},
+        {"host crit below global warn", "db01 crit=50ms", 100 * time.Millisecond, 0, up(60), stateDegraded, "critical latency 60 ms >= 50 ms", true},
+        {"host thresholds without globals", "db01 warn=10 crit=30", 0, 0, up(20), stateDegraded, "latency 20 ms >= 10 ms", false},
+        {"dual rated by slower family", "db01", 100 * time.Millisecond, 500 * time.Millisecond,
+            pingResult{state: stateUp, status: true, reply: 20, dual: true, status6: true, reply6: 600},
+            stateDegraded, "critical latency 600 ms >= 500 ms", true},
+        {"reason appended", "db01", 100 * time.Millisecond, 500 * time.Millisecond,
+            pingResult{state: stateDegraded, status: true, reply: 150, reason: "packet loss"},
+            stateDegraded, "packet loss, latency 150 ms >= 100 ms", false},
+        {"no reply time", "db01", 100 * time.Millisecond, 500 * time.Millisecond, up(-1), stateUp, "", false},
+        {"down", "db01", 100 * time.Millisecond, 500 * time.Millisecond,
+            pingResult{state: stateDown, reply: 900, reason: "timeout"}, stateDown, "timeout", false},
+    }
+    for _, tt := range tests {
+        m := newTestModel(t, tt.spec)
+        m.latWarn, m.latCrit = tt.warn, tt.crit
+        got := m.rateLatency(m.hosts[0], tt.res)
+        if got.state != tt.state || got.reason != tt.reason || got.critical != tt.critical {
+            t.Errorf("%s: rateLatency = %v %q critical %v, want %v %q critical %v",
+                tt.name, got.state, got.reason, got.critical, tt.state, tt.reason, tt.critical)
+        }
+    }
+}
+
+func TestCriticalLatencyAlerts(t *testing.T) {
+    m := newTestModel(t, "db01")
+    m.latWarn, m.latCrit = 100*time.Millisecond, 500*time.Millisecond
+    id := idOf(m, "db01")
+    steps := []struct {
+        rtt     float64
+        status  string
+        alerted bool
+    }{
+        {150, "DEGRADED", false}, // the first round never alerts
+        {600, "CRITICAL", true},  // DEGRADED still, but now critical
+        {700, "CRITICAL", false}, // critical already
+        {150, "DEGRADED", false},
+        {50, "UP", true},
+        {900, "CRITICAL", true},
+    }
+    for i, s := range steps {
+        // Forget the previous alert
+        m.message = ""
+        prev := m.results[id]
+        prev.flashUntil = time.Time{}
+        m.results[id] = prev
+        m, _ = send(m, upResult(m.schedGen, id, uint64(i+1), s.rtt))
+        res := m.results[id]
+        if got := res.statusText(); got != s.status {
+            t.Errorf("round %d (%v ms): status %s, want %s", i+1, s.rtt, got, s.status)
+        }
+        if alerted := !res.flashUntil.IsZero() && m.message != ""; alerted != s.alerted {
+            t.Errorf("round %d (%v ms): alerted %v (message %q), want %v", i+1, s.rtt, alerted, m.message, s.alerted)
+        }
+    }
+    if !strings.HasPrefix(m.message, "db01 is DEGRADED") || !strings.Contains(m.message, "critical latency 900 ms") {
+        t.Errorf("message %q, want db01's critical latency", m.message)
+    }
+}
+
+func TestSortByStatusRanksCritical(t *testing.T) {
+    m := newTestModel(t, "a-down", "b-critical", "c-degraded", "d-up", "e-pending")
+    m.latWarn, m.latCrit = 100*time.Millisecond, 500*time.Millisecond
+    m, _ = send(m,
+        downResult(m.schedGen, idOf(m, "a-down"), 1),
+        upResult(m.schedGen, idOf(m, "b-critical"), 1, 600),
+        upResult(m.schedGen, idOf(m, "c-degraded"), 1, 150),
+        upResult(m.schedGen, idOf(m, "d-up"), 1, 10),
+    )
+    m.sortBy = "status"
+    m.sortHosts()
+    var got []string
+    for _, h := range m.hosts {
+        got = append(got, h.Host)
+    }
+    if want := "d-up c-degraded b-critical a-down e-pending"; strings.Join(got, " ") != want {
+        t.Errorf("sorted by status: %s, want %s", strings.Join(got, " "), want)
+    }
+}
+
+// roundsOf returns the messages of a sequence of rounds against the host
+// with the given ID, "u" for one that answered and "d" for one that didn't,
+// numbered from round on.
+func roundsOf(gen, id int, round uint64, seq string) []hostResultMsg {
+    var msgs []hostResultMsg
+    for i, c := range seq {
+        if c == 'u' {
+            msgs = append(msgs, upResult(gen, id, round+uint64(i), 10))
+        } else {
+            msgs = append(msgs, downResult(gen, id, round+uint64(i)))
+        }
+    }
+    return msgs
+}
+
+func TestSettle(t *testing.T) {
+    // want lists the state after each round, with a "?" while suspect
+    tests := []struct {
+        name       string
+        spec       string
+        fall, rise int
+        seq        string
+        want       string
+    }{
+        {"thresholds of one", "db01", 1, 1, "udud", "UP DOWN UP DOWN"},
+        {"fall and rise", "db01", 3, 2, "uddduu", "UP UP? UP? DOWN DOWN? UP"},
+        {"first round applies at once", "db01", 3, 3, "ddu", "DOWN DOWN DOWN?"},
+        {"opposite result resets the count", "db01", 3, 2, "uddudddd", "UP UP? UP? UP UP? UP? DOWN DOWN"},
+        {"agreeing rounds don't count", "db01", 1, 3, "duuduuu", "DOWN DOWN? DOWN? DOWN DOWN? DOWN? UP"},
+        {"host overrides", "db01 fall=2 rise=3", 5, 5, "udduuu", "UP UP? DOWN DOWN? DOWN? UP"},
+        {"host override of one", "db01 fall=1", 5, 2, "udu", "UP DOWN DOWN?"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := newTestModel(t, tt.spec)
+            m.fall, m.rise = tt.fall, tt.rise
+            id := m.hosts[0].ID
+            var got []string
+            for _, msg := range roundsOf(m.schedGen, id, 1, tt.seq) {
+                prev := m.results[id]
+                m, _ = send(m, msg)
+                res := m.results[id]
+                text := res.state.String()
+                if res.suspect {
+                    text += "?"
+                    if res.statusText() != "SUSPECT" {
+                        t.Errorf("round %d shows %s, want SUSPECT", msg.res.round, res.statusText())
+                    }
+                    if !res.lastChange.Equal(prev.lastChange) || !res.flashUntil.Equal(prev.flashUntil) {
+                        t.Errorf("round %d changed the last change time or highlighted the row", msg.res.round)
+                    }
+                }
+                got = append(got, text)
+            }
+            if strings.Join(got, " ") != tt.want {
+                t.Errorf("states %s, want %s", strings.Join(got, " "), tt.want)
+            }
+        })
+    }
+}
+
+func TestFlapping(t *testing.T) {
+    m := newTestModel(t, "db01")
+    id := idOf(m, "db01")
+    // expire moves that many of the oldest changes out of the window before
+    // the round
+    steps := []struct {
+        seq     string
+        expire  int
+        status  string
+        alerted bool
+        message string // prefix of the message, "" for none
+    }{
+        {"u", 0, "UP", false, ""}, // the first round never alerts
+        {"d", 0, "DOWN", true, "db01 is DOWN"},
+        {"u", 0, "UP", true, "db01 is UP"},
+        {"d", 0, "DOWN", true, "db01 is DOWN"},
+        {"u", 0, "UP", true, "db01 is UP"},
+        {"d", 0, "DOWN", true, "db01 is DOWN"},
+        {"u", 0, "FLAPPING", false, "db01 is flapping (6 changes/5m)"},
+        {"d", 0, "FLAPPING", false, ""},
+        {"d", 3, "FLAPPING", false, ""}, // 4 changes left
+        {"d", 2, "DOWN", false, "db01 stopped flapping"},
+        {"u", 0, "UP", true, "db01 is UP"},
+    }
+    for i, s := range steps {
+        // Forget the previous alert
+        m.message = ""
+        prev := m.results[id]
+        prev.flashUntil = time.Time{}
+        for k := 0; k < s.expire && k < len(prev.changes); k++ {
+            prev.changes[k] = prev.changes[k].Add(-flapWindow)
+        }
+        m.results[id] = prev
+        m, _ = send(m, roundsOf(m.schedGen, id, uint64(i+1), s.seq)[0])
+        res := m.results[id]
+        if got := res.statusText(); got != s.status {
+            t.Errorf("round %d: status %s, want %s", i+1, got, s.status)
+        }
+        if alerted := !res.flashUntil.IsZero(); alerted != s.alerted {
+            t.Errorf("round %d: alerted %v, want %v", i+1, alerted, s.alerted)
+        }
+        if s.message == "" && m.message != "" || !strings.HasPrefix(m.message, s.message) {
+            t.Errorf("round %d: message %q, want %q", i+1, m.message, s.message)
+        }
+    }
+    if res := m.results[id]; len(res.changes) != 3 || res.flapText() != "3 changes/5m" {
+        t.Errorf("changes %v (%s), want the last 3", res.changes, res.flapText())
+    }
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+    "time"
+)
+
+// roundStats summarises the probes sent to a host during one round.
+type roundStats struct {
+    sent, recv          int
+    timed               int     // answered probes with a usable reply time
+    min, avg, max, mdev float64 // over timed probes, in milliseconds
+}
+
+// statsOf computes round statistics from the reply times of the answered
+// probes and the number of probes sent and answered. recv may exceed
+// len(rtts) when a probe succeeded without a usable reply time. mdev follows
+// ping's definition, the standard deviation of the reply times.
+func statsOf(rtts []float64, sent, recv int) roundStats {
+    st := roundStats{sent: sent, recv: recv, timed: len(rtts)}
+    if len(rtts) == 0 {
+        return st
+    }
+    st.min, st.max = rtts[0], rtts[0]
+    var sum, sumSq float64
+    for _, v := range rtts {
+        if v < st.min {
+            st.min = v
+        }
+        if v > st.max {
+            st.max = v
+        }
+        sum += v
+        sumSq += v * v
+    }
+    n := float64(len(rtts))
+    st.avg = sum / n
+    st.mdev = math.Sqrt(math.Max(sumSq/n-st.avg*st.avg, 0))
+    return st
+}
+
+// loss returns the percentage of probes that went unanswered.
+func (st roundStats) loss() float64 {
+    if st.sent == 0 {
+        return 0
+    }
+    return 100 * float64(st.sent-st.recv) / float64(st.sent)
+}
+
+// lossText renders the LOSS column, or "-" before the first round.
+func (st roundStats) lossText() string {
+    if st.sent == 0 {
+        return "-"
+    }
+    return fmt.Sprintf("%.0f%%", st.loss())
+}
+
+// rttText renders the MIN/AVG/MAX/MDEV column, or "-" if nothing answered.
+func (st roundStats) rttText() string {
+    if st.timed == 0 {
+        return "-"
+    }
+    return fmt.Sprintf("%.1f/%.1f/%.1f/%.1f", st.min, st.avg, st.max, st.mdev)
+}
+
+// percentile returns the p‑th percentile (0–100) of sorted values using
+// linear interpolation between the closest ranks, or 0 for no values.
+func percentile(sorted []float64, p float64) float64 {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := p / 100 * float64(len(sorted)-1)
+    lo := int(math.Floor(rank))
+    hi := int(math.Ceil(rank))
+    return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// weighted is a value that counts weight times.
+type weighted struct {
+    v      float64
+    weight int
+}
+
+// weightedPercentile returns the p‑th percentile (0–100) of vals, each
+// counting as often as its weight, by the nearest rank; 0 for no values.
+// vals is sorted in place.
+func weightedPercentile(vals []weighted, p float64) float64 {
+    total := 0
+    for _, w := range vals {
+        total += w.weight
+    }
+    if total == 0 {
+        return 0
+    }
+    sort.Slice(vals, func(i, j int) bool { return vals[i].v < vals[j].v })
+    rank := int(math.Ceil(p / 100 * float64(total)))
+    seen := 0
+    for _, w := range vals {
+        seen += w.weight
+        if seen >= rank {
+            return w.v
+        }
+    }
+    return vals[len(vals)-1].v
+}
+
+// defaultStatWindow is how far back the latency statistics look unless
+// configured otherwise; the history limits how far they can look.
+const (
+    defaultStatWindow = 5 * time.Minute
+    minStatWindow     = 10 * time.Second
+    maxStatWindow     = 24 * time.Hour
+)
+
+// latencyStats summarises a host's reply times over its stats window.
+type latencyStats struct {
+    n                  int // rounds with a reply time
+    p50, p90, p95, p99 float64
+    jitter, stddev     float64
+}
+
+// latencyOf computes latency statistics from samples, oldest first. Rounds
+// without a reply time are skipped. jitter is the interarrival jitter
+// estimator of RFC 3550, section 6.4.1, applied to consecutive reply times:
+// each difference D moves it by (|D| - jitter) / 16.
+func latencyOf(samples []sample) latencyStats {
+    var rtts []float64
+    for _, s := range samples {
+        if s.rtt >= 0 && !s.lost() {
+            rtts = append(rtts, float64(s.rtt))
+        }
+    }
+    st := latencyStats{n: len(rtts)}
+    if len(rtts) == 0 {
+        return st
+    }
+    var sum, sumSq float64
+    for i, v := range rtts {
+        sum += v
+        sumSq += v * v
+        if i > 0 {
+            st.jitter += (math.Abs(v-rtts[i-1]) - st.jitter) / 16
+        }
+    }
+    n := float64(len(rtts))
+    avg := sum / n
+    st.stddev = math.Sqrt(math.Max(sumSq/n-avg*avg, 0))
+    sort.Float64s(rtts)
+    st.p50 = percentile(rtts, 50)
+    st.p90 = percentile(rtts, 90)
+    st.p95 = percentile(rtts, 95)
+    st.p99 = percentile(rtts, 99)
+    return st
+}
+
+// latencyStatsFor returns the latency statistics of h over its stats window,
+// computed from its history on demand; see history.latency.
+func (m model) latencyStatsFor(h Host) latencyStats {
+    return m.history[h.ID].latency(m.statWindowFor(h))
+}
+
+// statColumn is an optional table column showing one latency or uptime
+// statistic. Its key also serves as sort key and as its name in the options
+// dialog. value returns the statistic and whether the host has any data yet,
+// given the host's result and latency statistics.
+type statColumn struct {
+    key, title string
+    value      func(pingResult, latencyStats) (float64, bool)
+    percent    bool
+}
+
+// statWidth fits any value of a statistic column: reply times up to
+// 99999.9 ms and percentages up to 100.00%. Columns have a fixed width so
+// that only the visible rows need their statistics.
+const statWidth = len("100.00%")
+
+// latColumn returns the value function of a latency column.
+func latColumn(f func(latencyStats) float64) func(pingResult, latencyStats) (float64, bool) {
+    return func(_ pingResult, lat latencyStats) (float64, bool) { return f(lat), lat.n > 0 }
+}
+
+// upColumn returns the value function of the uptime column over the k-th
+// uptimeWindows entry.
+func upColumn(k int) func(pingResult, latencyStats) (float64, bool) {
+    return func(res pingResult, _ latencyStats) (float64, bool) { return res.uptime.pct[k], res.uptime.known[k] }
+}
+
+// statColumns lists the optional columns in table order.
+var statColumns = []statColumn{
+    {"p50", "P50", latColumn(func(st latencyStats) float64 { return st.p50 }), false},
+    {"p90", "P90", latColumn(func(st latencyStats) float64 { return st.p90 }), false},
+    {"p95", "P95", latColumn(func(st latencyStats) float64 { return st.p95 }), false},
+    {"p99", "P99", latColumn(func(st latencyStats) float64 { return st.p99 }), false},
+    {"jitter", "JITTER", latColumn(func(st latencyStats) float64 { return st.jitter }), false},
+    {"stddev", "STDDEV", latColumn(func(st latencyStats) float64 { return st.stddev }), false},
+    {"up1h", "UP 1H", upColumn(0), true},
+    {"up24h", "UP 24H", upColumn(1), true},
+    {"up7d", "UP 7D", upColumn(2), true},
+    {"up30d", "UP 30D", upColumn(3), true},
+}
+
+// statColumnFor returns the column with the given key.
+func statColumnFor(key string) (statColumn, bool) {
+    for _, c := range statColumns {
+        if c.key == key {
+            return c, true
+        }
+    }
+    return statColumn{}, false
+}
+
+// text renders the column's value for a host, or "-" without data.
+func (c statColumn) text(res pingResult, lat latencyStats) string {
+    v, ok := c.value(res, lat)
+    switch {
+    case !ok:
+        return "-"
+    case c.percent:
+        return fmt.Sprintf("%.2f%%", v)
+    }
+    return fmt.Sprintf("%.1f", v)
+}
+
+// parseStatColumns parses a comma or space separated list of column keys
+// as entered in the options dialog. The result is in table order.
+func parseStatColumns(s string) ([]string, error) {
+    want := make(map[string]bool)
+    for _, key := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ',' || r == ' ' }) {
+        if _, ok := statColumnFor(key); !ok {
+            return nil, fmt.Errorf("unknown column %q", key)
+        }
+        want[key] = true
+    }
+    var keys []string
+    for _, c := range statColumns {
+        if want[c.key] {
+            keys = append(keys, c.key)
+        }
+    }
+    return keys, nil
+}
+
+// statWindowFor returns the latency statistics window for h: its own window
+// option if set, otherwise the global setting.
+func (m model) statWindowFor(h Host) time.Duration {
+    if h.Window > 0 {
+        return h.Window
+    }
+    if m.statWindow > 0 {
+        return m.statWindow
+    }
+    return defaultStatWindow
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// The store keeps every round and state change on disk so that history
+// survives a restart. It is a directory of append‑only segment files, one
+// per UTC day, holding one tab separated record per line:
+//
+//    R  <unix ms>  <host>  <state>  <sent>  <recv>  <rtt ms>  <reason>
+//    T  <unix ms>  <host>  <from state>  <to state>  <reason>
+//    A  <unix ms>  <host>  <rounds>  <up rounds>  <sent>  <recv>  <timed>  <rtt sum>
+//
+// Hosts are identified by their spec, like when reloading hosts.txt, and
+// reasons are URL‑escaped. Raw segments (.seg) older than compactAfter are
+// compacted into .cseg files in which the round records of each host are
+// merged into one aggregate per minute; transitions are kept as they are.
+// Segments older than the retention are deleted. This maintenance runs in
+// the background when the store is opened and whenever a new day starts,
+// while rounds go on being appended. A line cut short by a crash is skipped
+// when reading.
+
+const (
+    dataDir        = "mping-data"
+    storeRetention = 35 * 24 * time.Hour
+    compactAfter   = 48 * time.Hour
+
+    // recordErrorEvery is how often failing writes are reported in the
+    // message line at most.
+    recordErrorEvery = time.Minute
+)
+
+// Record kinds.
+const (
+    recRound      = 'R'
+    recTransition = 'T'
+    recAggregate  = 'A'
+)
+
+// record is one line of a segment.
+type record struct {
+    kind  byte
+    at    time.Time
+    key   string    // host spec
+    state hostState // R: state after the round; T: new state
+    from  hostState // T: previous state
+
+    // R and A: probes sent and answered. rtt is the average reply time of
+    // an R record, -1 if there was none.
+    sent, recv int
+    rtt        float64
+    reason     string
+
+    // A: rounds merged, how many of them were up (or degraded), how many
+    // had a reply time and the sum of those reply times.
+    rounds, up, timed int
+    rttSum            float64
+}
+
+// stateNames maps the STATUS labels back to states when reading records.
+var stateNames = map[string]hostState{
+    "PENDING":  statePending,
+    "UP":       stateUp,
+    "DEGRADED": stateDegraded,
+    "DOWN":     stateDown,
+    "ERROR":    stateError,
+}
+
+// encode formats the record as a segment line, without the newline.
+func (r record) encode() string {
+    f := []string{string(r.kind), strconv.FormatInt(r.at.UnixMilli(), 10), r.key}
+    switch r.kind {
+    case recRound:
+        f = append(f, r.state.String(), strconv.Itoa(r.sent), strconv.Itoa(r.recv),
+            strconv.FormatFloat(r.rtt, 'f', 3, 64), url.QueryEscape(r.reason))
+    case recTransition:
+        f = append(f, r.from.String(), r.state.String(), url.QueryEscape(r.reason))
+    case recAggregate:
+        f = append(f, strconv.Itoa(r.rounds), strconv.Itoa(r.up), strconv.Itoa(r.sent),
+            strconv.Itoa(r.recv), strconv.Itoa(r.timed), strconv.FormatFloat(r.rttSum, 'f', 3, 64))
+    }
+    return strings.Join(f, "\t")
+}
+
+// parseRecord parses a segment line.
+func parseRecord(line string) (record, error) {
+    f := strings.Split(line, "\t")
+    if len(f) < 3 || len(f[0]) != 1 {
+        return record{}, fmt.Errorf("malformed record")
+    }
+    ms, err := strconv.ParseInt(f[1], 10, 64)
+    if err != nil {
+        return record{}, fmt.Errorf("bad time: %v", err)
+    }
+    r := record{kind: f[0][0], at: time.UnixMilli(ms), key: f[2]}
+    // ints parses the fields from index at on into dst.
+    ints := func(at int, dst ...*int) error {
+        for i, d := range dst {
+            n, err := strconv.Atoi(f[at+i])
+            if err != nil {
+                return err
+            }
+            *d = n
+        }
+        return nil
+    }
+    state := func(s string) (hostState, error) {
+        st, ok := stateNames[s]
+        if !ok {
+            return 0, fmt.Errorf("unknown state %q", s)
+        }
+        return st, nil
+    }
+    switch r.kind {
+    case recRound:
+        if len(f) != 8 {
+            return record{}, fmt.Errorf("malformed round record")
+        }
+        if r.state, err = state(f[3]); err != nil {
+            return record{}, err
+        }
+        if err := ints(4, &r.sent, &r.recv); err != nil {
+            return record{}, err
+        }
+        if r.rtt, err = strconv.ParseFloat(f[6], 64); err != nil {
+            return record{}, err
+        }
+        r.reason, err = url.QueryUnescape(f[7])
+    case recTransition:
+        if len(f) != 6 {
+            return record{}, fmt.Errorf("malformed transition record")
+        }
+        if r.from, err = state(f[3]); err != nil {
+            return record{}, err
+        }
+        if r.state, err = state(f[4]); err != nil {
+            return record{}, err
+        }
+        r.reason, err = url.QueryUnescape(f[5])
+    case recAggregate:
+        if len(f) != 9 {
+            return record{}, fmt.Errorf("malformed aggregate record")
+        }
+        if err := ints(3, &r.rounds, &r.up, &r.sent, &r.recv, &r.timed); err != nil {
+            return record{}, err
+        }
+        r.rttSum, err = strconv.ParseFloat(f[8], 64)
+    default:
+        return record{}, fmt.Errorf("unknown record kind %q", f[0])
+    }
+    return r, err
+}
+
+// roundRecord returns the record of a finished round of the host with the
+// given spec.
+func roundRecord(at time.Time, key string, res pingResult) record {
+    r := record{kind: recRound, at: at, key: key, state: res.state,
+        sent: res.stats.sent, recv: res.stats.recv, rtt: -1, reason: res.reasonOnly()}
+    if res.stats.timed > 0 {
+        r.rtt = res.stats.avg
+    }
+    return r
+}
+
+// store is an open data directory. Its methods are safe for concurrent use.
+type store struct {
+    dir string
+
+    mu   sync.Mutex
+    f    *os.File // segment currently appended to
+    day  string   // day of that segment
+    torn bool     // the last write failed, maybe after part of a line
+
+    // maintaining is set while a background maintenance runs. maintErr is
+    // the error it ran into, kept until taken by maintenanceError.
+    maintaining bool
+    maintErr    error
+}
+
+// openStore opens the data directory, creating it if needed, and starts
+// removing or compacting old segments in the background.
+func openStore(dir string) (*store, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, err
+    }
+    s := &store{dir: dir}
+    s.mu.Lock()
+    s.maintainLocked(time.Now())
+    s.mu.Unlock()
+    return s, nil
+}
+
+// segmentDay returns the day a segment file belongs to, e.g. "20260101".
+func segmentDay(t time.Time) string {
+    return t.UTC().Format("20060102")
+}
+
+// append writes records to the segment of the current day. Crossing into a
+// new day starts a new segment and maintains the old ones in the background.
+func (s *store) append(recs ...record) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    if day := segmentDay(now); day != s.day || s.f == nil {
+        if s.f != nil {
+            s.f.Close()
+            s.f = nil
+            s.maintainLocked(now)
+        }
+        f, err := os.OpenFile(filepath.Join(s.dir, day+".seg"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+        if err != nil {
+            return err
+        }
+        s.f, s.day = f, day
+    }
+    var b strings.Builder
+    if s.torn {
+        // End a line cut short by the failed write, so that only it is
+        // skipped when reading and not these records too
+        b.WriteByte('\n')
+    }
+    for _, r := range recs {
+        b.WriteString(r.encode())
+        b.WriteByte('\n')
+    }
+    _, err := s.f.WriteString(b.String())
+    s.torn = err != nil
+    return err
+}
+
+// close closes the current segment. It does nothing on a nil store.
+func (s *store) close() error {
+    if s == nil {
+        return nil
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.f == nil {
+        return nil
+    }
+    err := s.f.Close()
+    s.f = nil
+    return err
+}
+
+// segment is a segment file found in the data directory.
+type segment struct {
+    path      string
+    day       time.Time
+    compacted bool
+}
+
+// segments lists the segment files of dir ordered by day.
+func segments(dir string) ([]segment, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+    var segs []segment
+    for _, e := range entries {
+        name := e.Name()
+        ext := filepath.Ext(name)
+        if ext != ".seg" && ext != ".cseg" {
+            continue
+        }
+        day, err := time.Parse("20060102", strings.TrimSuffix(name, ext))
+        if err != nil {
+            continue
+        }
+        segs = append(segs, segment{path: filepath.Join(dir, name), day: day, compacted: ext == ".cseg"})
+    }
+    sort.Slice(segs, func(i, j int) bool { return segs[i].day.Before(segs[j].day) })
+    // A day found both raw and compacted is being compacted right now; the
+    // compacted segment is complete already and replaces the raw one.
+    out := segs[:0]
+    for _, seg := range segs {
+        if n := len(out); n > 0 && out[n-1].day.Equal(seg.day) {
+            if seg.compacted {
+                out[n-1] = seg
+            }
+            continue
+        }
+        out = append(out, seg)
+    }
+    return out, nil
+}
+
+// maintainLocked starts maintaining the data directory in the background
+// unless that is already under way. s.mu must be held.
+func (s *store) maintainLocked(now time.Time) {
+    if s.maintaining {
+        return
+    }
+    s.maintaining = true
+    go func() {
+        err := maintainDir(s.dir, now)
+        s.mu.Lock()
+        s.maintaining = false
+        if err != nil {
+            s.maintErr = err
+        }
+        s.mu.Unlock()
+    }()
+}
+
+// maintenanceError returns the error the last background maintenance ran
+// into, once, or nil.
+func (s *store) maintenanceError() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    err := s.maintErr
+    s.maintErr = nil
+    return err
+}
+
+// maintainDir deletes the segments of dir past the retention and compacts
+// raw segments older than compactAfter. A segment that fails doesn't stop
+// the others; the first error is returned.
+func maintainDir(dir string, now time.Time) error {
+    segs, err := segments(dir)
+    if err != nil {
+        return err
+    }
+    var first error
+    for _, seg := range segs {
+        end := seg.day.Add(24 * time.Hour)
+        switch {
+        case now.Sub(end) > storeRetention:
+            err = os.Remove(seg.path)
+        case !seg.compacted && now.Sub(end) > compactAfter:
+            if err = compactSegment(seg.path); err != nil {
+                err = fmt.Errorf("compacting %s: %v", filepath.Base(seg.path), err)
+            }
+        }
+        if err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
+
+// compactSegment merges the round records of a raw segment into one
+// aggregate per host and minute and replaces it by a .cseg file.
+func compactSegment(path string) error {
+    type bucket struct {
+        key string
+        at  int64
+    }
+    aggs := make(map[bucket]*record)
+    var out []record
+    err := readSegment(path, func(r record) bool {
+        if r.kind != recRound {
+            out = append(out, r)
+            return true
+        }
+        minute := r.at.Truncate(time.Minute)
+        b := bucket{r.key, minute.UnixMilli()}
+        a := aggs[b]
+        if a == nil {
+            a = &record{kind: recAggregate, at: minute, key: r.key}
+            aggs[b] = a
+        }
+        a.rounds++
+        if r.state == stateUp || r.state == stateDegraded {
+            a.up++
+        }
+        a.sent += r.sent
+        a.recv += r.recv
+        if r.rtt >= 0 {
+            a.timed++
+            a.rttSum += r.rtt
+        }
+        return true
+    })
+    if err != nil {
+        return err
+    }
+    for _, a := range aggs {
+        out = append(out, *a)
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].at.Before(out[j].at) })
+    tmp := strings.TrimSuffix(path, ".seg") + ".tmp"
+    f, err := os.Create(tmp)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(f)
+    for _, r := range out {
+        w.WriteString(r.encode())
+        w.WriteByte('\n')
+    }
+    if err := w.Flush(); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+    if err := os.Rename(tmp, strings.TrimSuffix(path, ".seg")+".cseg"); err != nil {
+        return err
+    }
+    return os.Remove(path)
+}
+
+// readSegment calls fn for each record of a segment file until fn returns
+// false. Malformed lines are skipped.
+func readSegment(path string, fn func(record) bool) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    sc := bufio.NewScanner(f)
+    sc.Buffer(make([]byte, 64*1024), 1024*1024)
+    for sc.Scan() {
+        r, err := parseRecord(sc.Text())
+        if err != nil {
+            continue
+        }
+        if !fn(r) {
+            break
+        }
+    }
+    return sc.Err()
+}
+
+// scan calls fn for each record taken in [from, to), oldest segment first,
+// until fn returns false.
+func (s *store) scan(from, to time.Time, fn func(record) bool) error {
+    return scanDir(s.dir, from, to, fn)
+}
+
+// scanDir is scan for a data directory that isn't open, as used by the
+// command line tools.
+func scanDir(dir string, from, to time.Time, fn func(record) bool) error {
+    segs, err := segments(dir)
+    if err != nil {
+        return err
+    }
+    stop := false
+    for _, seg := range segs {
+        if !seg.day.Add(24*time.Hour).After(from) || !seg.day.Before(to) {
+            continue
+        }
+        read := func(r record) bool {
+            if r.at.Before(from) || !r.at.Before(to) {
+                return true
+            }
+            if !fn(r) {
+                stop = true
+                return false
+            }
+            return true
+        }
+        err := readSegment(seg.path, read)
+        if os.IsNotExist(err) && !seg.compacted {
+            // Compacted since it was listed
+            err = readSegment(strings.TrimSuffix(seg.path, ".seg")+".cseg", read)
+        }
+        if err != nil || stop {
+            return err
+        }
+    }
+    return nil
+}
+
+// record writes the outcome of a round, and the state change it caused if
+// any, to the store. A host whose first result finds it unreachable is
+// recorded as changing from PENDING, so the incident log picks up that
+// outage on the next start. A failed write loses that round only: the next
+// one is written as usual, since a full disk or a file system hiccup may
+// well pass. Failures are reported in the message line at most every
+// recordErrorEvery, and once writing works again.
+func (m *model) record(h Host, prev, res pingResult, changed bool, now time.Time) {
+    if m.store == nil {
+        return
+    }
+    recs := []record{roundRecord(now, h.spec(), res)}
+    if changed || prev.state == statePending && !res.up() {
+        recs = append(recs, record{kind: recTransition, at: now, key: h.spec(),
+            from: prev.state, state: res.state, reason: res.reasonOnly()})
+    }
+    if err := m.store.append(recs...); err != nil {
+        m.recordFails++
+        if now.Sub(m.recordReported) >= recordErrorEvery {
+            m.setMessage(fmt.Sprintf("Failed to record results: %v (%d rounds lost)", err, m.recordFails))
+            m.recordReported = now
+        }
+        return
+    }
+    if m.recordFails > 0 {
+        m.setMessage(fmt.Sprintf("Recording again after %d lost rounds", m.recordFails))
+        m.recordFails, m.recordReported = 0, time.Time{}
+    }
+    // Recording goes on if compacting or removing old segments failed
+    if err := m.store.maintenanceError(); err != nil {
+        m.setMessage("Store maintenance failed: " + err.Error())
+    }
+}
+
+// storedHistory is what loadHistoryCmd read from the store: the history and
+// uptime counts of the hosts listed when it started, by host spec, and the
+// incident log with the time each host with an ongoing incident was last
+// recorded.
+type storedHistory struct {
+    until         time.Time // end of the rounds read; later ones are live
+    history       map[string]*history
+    uptime        map[string]*uptime
+    incidents     []incident
+    openIncidents map[string]int
+    lastSeen      map[string]time.Time
+}
+
+// historyLoadedMsg delivers the history read by loadHistoryCmd.
+type historyLoadedMsg struct {
+    stored storedHistory
+    err    error
+}
+
+// loadHistoryCmd returns a command that reads what the store holds about
+// the current hosts: a day of rounds for the history, and everything within
+// the longest uptime window for the uptime. The incident log is rebuilt from
+// all records kept, including those of hosts no longer listed. Reading up to
+// storeRetention of segments can take a while, so it runs while the hosts
+// are probed already; mergeHistory puts the two together.
+func (m model) loadHistoryCmd() tea.Cmd {
+    st := m.store
+    sizes := make(map[string]int, len(m.hosts))
+    for _, h := range m.hosts {
+        sizes[h.spec()] = m.historySizeFor(h)
+    }
+    // Records keep milliseconds; rounds recorded from now on are live ones
+    now := time.Now().Truncate(time.Millisecond)
+    return func() tea.Msg {
+        sh := storedHistory{until: now, history: make(map[string]*history), uptime: make(map[string]*uptime)}
+        // A scratch model follows the states into the incident log
+        var log model
+        last := make(map[string]time.Time)
+        recent := now.Add(-24 * time.Hour)
+        upFrom := now.Add(-uptimeWindows[len(uptimeWindows)-1])
+        err := st.scan(now.Add(-storeRetention), now, func(r record) bool {
+            last[r.key] = r.at
+            switch r.kind {
+            case recTransition:
+                log.noteState(r.key, r.from, r.state, true, r.reason, r.at)
+            case recRound:
+                log.noteState(r.key, r.state, r.state, false, "", r.at)
+            case recAggregate:
+                if r.up > 0 {
+                    log.noteState(r.key, stateUp, stateUp, false, "", r.at)
+                }
+            }
+            size, ok := sizes[r.key]
+            if !ok || r.kind == recTransition || r.at.Before(upFrom) {
+                return true
+            }
+            u := sh.uptime[r.key]
+            if u == nil {
+                u = &uptime{}
+                sh.uptime[r.key] = u
+            }
+            if r.kind == recAggregate {
+                u.add(r.at, r.rounds, r.up)
+                return true
+            }
+            up := 0
+            if r.state == stateUp || r.state == stateDegraded {
+                up = 1
+            }
+            u.add(r.at, 1, up)
+            if r.at.Before(recent) {
+                return true
+            }
+            hist := sh.history[r.key]
+            if hist == nil {
+                hist = &history{}
+                hist.resize(size)
+                sh.history[r.key] = hist
+            }
+            hist.add(sample{at: r.at.UnixMilli(), rtt: float32(r.rtt),
+                sent: uint8(min(r.sent, 255)), recv: uint8(min(r.recv, 255))})
+            return true
+        })
+        sh.incidents, sh.openIncidents = log.incidents, log.openIncidents
+        sh.lastSeen = make(map[string]time.Time, len(log.openIncidents))
+        for key := range log.openIncidents {
+            sh.lastSeen[key] = last[key]
+        }
+        return historyLoadedMsg{stored: sh, err: err}
+    }
+}
+
+// mergeHistory puts the history read from the store in front of the rounds
+// probed while it was read. An outage still open in the store that went on
+// in this run continues as one incident; one whose host is up by now ended
+// when the host was first seen up, and one of a host no longer listed when
+// it was last recorded.
+func (m *model) mergeHistory(sh storedHistory) {
+    done := make(map[string]bool)
+    for _, h := range m.hosts {
+        key := h.spec()
+        if done[key] {
+            continue
+        }
+        done[key] = true
+        if hist := sh.history[key]; hist != nil {
+            if live := m.history[h.ID]; live != nil {
+                for i := 0; i < live.len(); i++ {
+                    hist.add(live.at(i))
+                }
+            }
+            if m.history == nil {
+                m.history = make(map[int]*history)
+            }
+            m.history[h.ID] = hist
+            m.historyFor(h)
+        }
+        if u := sh.uptime[key]; u != nil {
+            if live := m.uptime[h.ID]; live != nil {
+                u.merge(live)
+            }
+            if m.uptime == nil {
+                m.uptime = make(map[int]*uptime)
+            }
+            m.uptime[h.ID] = u
+            if res, ok := m.results[h.ID]; ok {
+                res.uptime = u.stats(time.Now())
+                m.results[h.ID] = res
+            }
+        }
+    }
+
+    incidents, open := sh.incidents, sh.openIncidents
+    if open == nil {
+        open = make(map[string]int)
+    }
+    for _, in := range m.incidents {
+        if k, ok := open[in.key]; ok {
+            incidents[k].end = in.end
+            if !in.end.IsZero() {
+                delete(open, in.key)
+            }
+            continue
+        }
+        if in.end.IsZero() {
+            open[in.key] = len(incidents)
+        }
+        incidents = append(incidents, in)
+    }
+    for _, h := range m.hosts {
+        res, ok := m.results[h.ID]
+        k, isOpen := open[h.spec()]
+        if ok && isOpen && res.up() && incidents[k].start.Before(sh.until) {
+            incidents[k].end = res.lastChange
+            delete(open, h.spec())
+        }
+    }
+    listed := make(map[string]bool, len(m.hosts))
+    for _, h := range m.hosts {
+        listed[h.spec()] = true
+    }
+    for key, k := range open {
+        if at, ok := sh.lastSeen[key]; ok && !listed[key] && incidents[k].start.Before(sh.until) {
+            incidents[k].end = at
+            delete(open, key)
+        }
+    }
+    m.incidents, m.openIncidents = incidents, open
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+// at returns a record time in milliseconds after the given UTC day start.
+func at(day string, ms int64) time.Time {
+    t, err := time.Parse("20060102", day)
+    if err != nil {
+        panic(err)
+    }
+    return time.UnixMilli(t.UnixMilli() + ms)
+}
+
+// writeSegment writes lines, each followed by a newline, to a segment file
+// of dir.
+func writeSegment(t *testing.T, dir, name string, lines ...string) string {
+    t.Helper()
+    path := filepath.Join(dir, name)
+    if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+// readAll returns the records of a segment file.
+func readAll(t *testing.T, path string) []record {
+    t.Helper()
+    var recs []record
+    if err := readSegment(path, func(r record) bool {
+        recs = append(recs, r)
+        return true
+    }); err != nil {
+        t.Fatal(err)
+    }
+    return recs
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+    recs := []record{
+        {kind: recRound, at: at("20260101", 1234), key: "gnu.org", state: stateUp, sent: 3, recv: 3, rtt: 12.345},
+        {kind: recRound, at: at("20260101", 5000), key: "tcp://db01:5432 fall=3", state: stateDown,
+            sent: 1, recv: 0, rtt: -1, reason: "refused"},
+        {kind: recRound, at: at("20260101", 6000), key: "https://example.org match=a%20b", state: stateDegraded,
+            sent: 4, recv: 3, rtt: 80, reason: "packet loss, latency 80 ms >= 50 ms\twith tab"},
+        {kind: recTransition, at: at("20260101", 7000), key: "gnu.org", from: stateUp, state: stateError,
+            reason: "DNS failed"},
+        {kind: recTransition, at: at("20260101", 8000), key: "gnu.org", from: statePending, state: stateDown,
+            reason: "host unreachable from 10.0.0.1 (code 1)"},
+        {kind: recAggregate, at: at("20260101", 60000), key: "gnu.org", rounds: 12, up: 11,
+            sent: 36, recv: 33, timed: 11, rttSum: 135.5},
+    }
+    for _, want := range recs {
+        line := want.encode()
+        if strings.Contains(line, "\n") {
+            t.Errorf("encode(%+v) spans lines: %q", want, line)
+        }
+        got, err := parseRecord(line)
+        if err != nil {
+            t.Errorf("parseRecord(%q): %v", line, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, want) {
+            t.Errorf("parseRecord(%q) = %+v, want %+v", line, got, want)
+        }
+    }
+}
+
+func TestParseRecordRejects(t *testing.T) {
+    for _, line := range []string{
+        "",
+        "R",
+        "R\tsoon\tgnu.org\tUP\t1\t1\t1.000\t",
+        "R\t1000\tgnu.org\tUP\t1\t1",
+        "R\t1000\tgnu.org\tSIDEWAYS\t1\t1\t1.000\t",
+        "T\t1000\tgnu.org\tUP",
+        "A\t1000\tgnu.org\t1\t1\t1\t1\tone\t1.000",
+        "X\t1000\tgnu.org",
+    } {
+        if r, err := parseRecord(line); err == nil {
+            t.Errorf("parseRecord(%q) = %+v, want an error", line, r)
+        }
+    }
+}
+
+func TestReadSegmentSkipsTornLines(t *testing.T) {
+    dir := t.TempDir()
+    ok1 := record{kind: recRound, at: at("20260101", 1000), key: "gnu.org", state: stateUp, sent: 1, recv: 1, rtt: 5}
+    ok2 := record{kind: recRound, at: at("20260101", 2000), key: "gnu.org", state: stateUp, sent: 1, recv: 1, rtt: 6}
+    torn := record{kind: recRound, at: at("20260101", 3000), key: "gnu.org", state: stateUp, sent: 1, recv: 1, rtt: 7}
+    // A crash cut the third record short; the file was appended to after
+    // the restart.
+    line := torn.encode()
+    path := writeSegment(t, dir, "20260101.seg", ok1.encode(), line[:len(line)/2]+ok2.encode())
+    got := readAll(t, path)
+    if len(got) != 1 || !reflect.DeepEqual(got[0], ok1) {
+        t.Fatalf("read %+v, want only %+v", got, ok1)
+    }
+    path = writeSegment(t, dir, "20260102.seg", ok1.encode(), line[:len(line)-4], ok2.encode())
+    got = readAll(t, path)
+    if len(got) != 2 || !reflect.DeepEqual(got[1], ok2) {
+        t.Fatalf("read %+v, want %+v and %+v", got, ok1, ok2)
+    }
+}
+
+func TestCompactSegment(t *testing.T) {
+    dir := t.TempDir()
+    const day = "20260101"
+    round := func(ms int64, key string, state hostState, sent, recv int, rtt float64) string {
+        return record{kind: recRound, at: at(day, ms), key: key, state: state, sent: sent, recv: recv, rtt: rtt}.encode()
+    }
+    trans := record{kind: recTransition, at: at(day, 70_000), key: "a", from: stateUp, state: stateDown, reason: "timeout"}
+    path := writeSegment(t, dir, day+".seg",
+        round(0, "a", stateUp, 2, 2, 10),
+        round(30_000, "a", stateDegraded, 2, 1, 20),
+        round(10_000, "b", stateUp, 1, 1, 5),
+        round(65_000, "a", stateUp, 2, 2, 30),
+        trans.encode(),
+        round(70_000, "a", stateDown, 2, 0, -1),
+    )
+    if err := compactSegment(path); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(path); !os.IsNotExist(err) {
+        t.Errorf("raw segment still present: %v", err)
+    }
+    got := readAll(t, filepath.Join(dir, day+".cseg"))
+    agg := func(ms int64, key string, rounds, up, sent, recv, timed int, sum float64) record {
+        return record{kind: recAggregate, at: at(day, ms), key: key, rounds: rounds, up: up,
+            sent: sent, recv: recv, timed: timed, rttSum: sum}
+    }
+    want := map[string]record{
+        "A a 0":     agg(0, "a", 2, 2, 4, 3, 2, 30),
+        "A b 0":     agg(0, "b", 1, 1, 1, 1, 1, 5),
+        "A a 60000": agg(60_000, "a", 2, 1, 4, 2, 1, 30),
+        "T a 70000": trans,
+    }
+    if len(got) != len(want) {
+        t.Fatalf("compacted into %d records, want %d: %+v", len(got), len(want), got)
+    }
+    for k, r := range got {
+        if k > 0 && r.at.Before(got[k-1].at) {
+            t.Errorf("record %d at %v is out of order", k, r.at)
+        }
+        id := string(r.kind) + " " + r.key + " " + strconv.FormatInt(r.at.Sub(at(day, 0)).Milliseconds(), 10)
+        if w, ok := want[id]; !ok || !reflect.DeepEqual(r, w) {
+            t.Errorf("record %s = %+v, want %+v", id, r, w)
+        }
+    }
+}
+
+func TestMaintainDir(t *testing.T) {
+    dir := t.TempDir()
+    now := at("20260301", 12*3600*1000)
+    line := record{kind: recRound, at: at("20260101", 0), key: "a", state: stateUp, sent: 1, recv: 1, rtt: 1}.encode()
+    day := func(ago int) string { return segmentDay(now.AddDate(0, 0, -ago)) }
+    expired := writeSegment(t, dir, day(40)+".cseg", line)
+    expiredRaw := writeSegment(t, dir, day(37)+".seg", line)
+    old := writeSegment(t, dir, day(5)+".seg", line)
+    compacted := writeSegment(t, dir, day(6)+".cseg", line)
+    recent := writeSegment(t, dir, day(1)+".seg", line)
+    today := writeSegment(t, dir, day(0)+".seg", line)
+    writeSegment(t, dir, "notes.txt", "not a segment")
+    if err := maintainDir(dir, now); err != nil {
+        t.Fatal(err)
+    }
+    exists := func(path string) bool {
+        _, err := os.Stat(path)
+        return err == nil
+    }
+    for _, path := range []string{expired, expiredRaw, old} {
+        if exists(path) {
+            t.Errorf("%s kept", filepath.Base(path))
+        }
+    }
+    for _, path := range []string{strings.TrimSuffix(old, ".seg") + ".cseg", compacted, recent, today, filepath.Join(dir, "notes.txt")} {
+        if !exists(path) {
+            t.Errorf("%s missing", filepath.Base(path))
+        }
+    }
+    segs, err := segments(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(segs) != 4 {
+        t.Errorf("%d segments left, want 4: %+v", len(segs), segs)
+    }
+}
+
+func TestSegmentsPreferCompacted(t *testing.T) {
+    dir := t.TempDir()
+    writeSegment(t, dir, "20260101.seg", "")
+    writeSegment(t, dir, "20260101.cseg", "")
+    writeSegment(t, dir, "20260102.seg", "")
+    segs, err := segments(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(segs) != 2 || !segs[0].compacted || segs[1].compacted {
+        t.Fatalf("segments = %+v, want the compacted first day and the raw second day", segs)
+    }
+}
+
+func TestRecordRetriesAfterFailure(t *testing.T) {
+    dir := t.TempDir()
+    st, err := openStore(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer st.close()
+    m := newTestModel(t, "a.example")
+    m.store = st
+    a := idOf(m, "a.example")
+    m, _ = send(m, upResult(m.schedGen, a, 1, 10))
+
+    // Make the writes fail, as on a full disk
+    st.mu.Lock()
+    good := st.f
+    bad, err := os.Open(good.Name())
+    if err != nil {
+        t.Fatal(err)
+    }
+    st.f = bad
+    st.mu.Unlock()
+    m, _ = send(m, upResult(m.schedGen, a, 2, 20))
+    if m.store == nil || !strings.HasPrefix(m.message, "Failed to record results") {
+        t.Fatalf("store %v, message %q; want the store kept and the failure reported", m.store, m.message)
+    }
+    m.setMessage("")
+    m, _ = send(m, upResult(m.schedGen, a, 3, 30))
+    if m.message != "" {
+        t.Errorf("second failure within %v reported: %q", recordErrorEvery, m.message)
+    }
+
+    st.mu.Lock()
+    bad.Close()
+    st.f = good
+    st.mu.Unlock()
+    m, _ = send(m, upResult(m.schedGen, a, 4, 40))
+    if m.message != "Recording again after 2 lost rounds" {
+        t.Errorf("message %q after writing again", m.message)
+    }
+    var rtts []float64
+    for _, r := range readAll(t, good.Name()) {
+        if r.kind == recRound {
+            rtts = append(rtts, r.rtt)
+        }
+    }
+    if !reflect.DeepEqual(rtts, []float64{10, 40}) {
+        t.Errorf("recorded rounds with reply times %v, want 10 and 40", rtts)
+    }
+}
+
+func TestLoadHistoryWhileProbing(t *testing.T) {
+    st, err := openStore(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer st.close()
+    now := time.Now()
+    ago := func(d time.Duration) time.Time { return now.Add(-d) }
+    round := func(at time.Time, key string, state hostState, rtt float64) record {
+        r := record{kind: recRound, at: at, key: key, state: state, sent: 1, recv: 1, rtt: rtt}
+        if state == stateDown {
+            r.recv, r.rtt, r.reason = 0, -1, "timeout"
+        }
+        return r
+    }
+    down := func(at time.Time, key string) record {
+        return record{kind: recTransition, at: at, key: key, from: stateUp, state: stateDown, reason: "timeout"}
+    }
+    // The last run ended with a and b down; c's outage was over
+    if err := st.append(
+        round(ago(31*24*time.Hour), "a.example", stateUp, 1), // beyond the uptime windows
+        round(ago(3*time.Hour), "a.example", stateUp, 5),
+        round(ago(3*time.Hour), "b.example", stateUp, 6),
+        round(ago(3*time.Hour), "c.example", stateUp, 7),
+        down(ago(2*time.Hour), "a.example"),
+        round(ago(2*time.Hour), "a.example", stateDown, 0),
+        down(ago(2*time.Hour), "b.example"),
+        round(ago(2*time.Hour), "b.example", stateDown, 0),
+        down(ago(2*time.Hour), "c.example"),
+        round(ago(time.Hour), "c.example", stateUp, 8),
+        down(ago(2*time.Hour), "d.example"),
+        round(ago(90*time.Minute), "d.example", stateDown, 0),
+    ); err != nil {
+        t.Fatal(err)
+    }
+
+    m := newTestModel(t, "a.example", "b.example", "c.example")
+    a, b := idOf(m, "a.example"), idOf(m, "b.example")
+    m.store = st
+    m.setMessage(loadingHistory)
+    cmd := m.loadHistoryCmd()
+    // a comes back and b stays down before the history is read
+    m, _ = send(m, upResult(m.schedGen, a, 1, 10))
+    m, _ = send(m, downResult(m.schedGen, b, 1))
+    m.setMessage(loadingHistory)
+    m, _ = send(m, cmd())
+
+    if m.message != "" {
+        t.Errorf("message %q after loading", m.message)
+    }
+    var rtts []float32
+    for _, s := range m.history[a].last(m.history[a].len()) {
+        rtts = append(rtts, s.rtt)
+    }
+    if !reflect.DeepEqual(rtts, []float32{5, -1, 10}) {
+        t.Errorf("history of a.example has reply times %v, want the stored 5 and -1, then 10", rtts)
+    }
+    if pct, ok := m.uptime[a].percent(now, 24*time.Hour); !ok || pct != 100*2/3.0 {
+        t.Errorf("24h uptime of a.example %v, %v; want two of three rounds", pct, ok)
+    }
+    if up := m.results[a].uptime; !up.known[1] || up.pct[1] != 100*2/3.0 {
+        t.Errorf("result of a.example shows uptime %+v, want it updated", up)
+    }
+
+    if len(m.incidents) != 4 {
+        t.Fatalf("%d incidents, want one each: %+v", len(m.incidents), m.incidents)
+    }
+    for _, in := range m.incidents {
+        switch in.key {
+        case "a.example":
+            if !in.end.Equal(m.results[a].lastChange) {
+                t.Errorf("a.example's outage ended %v, want when it was first seen up, %v", in.end, m.results[a].lastChange)
+            }
+        case "b.example":
+            if !in.end.IsZero() || !in.start.Equal(ago(2*time.Hour).Truncate(time.Millisecond)) {
+                t.Errorf("b.example's outage %+v, want it open since the stored start", in)
+            }
+        case "c.example":
+            if !in.end.Equal(ago(time.Hour).Truncate(time.Millisecond)) {
+                t.Errorf("c.example's outage ended %v, want the stored end", in.end)
+            }
+        case "d.example":
+            if !in.end.Equal(ago(90 * time.Minute).Truncate(time.Millisecond)) {
+                t.Errorf("outage of d.example, which isn't listed any more, ended %v, want when it was last recorded", in.end)
+            }
+        }
+    }
+    if len(m.openIncidents) != 1 || m.incidents[m.openIncidents["b.example"]].key != "b.example" {
+        t.Errorf("open incidents %v, want only b.example's", m.openIncidents)
+    }
+}
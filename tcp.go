@@ -0,0 +1,34 @@
+package main
+
+import (
+    "context"
+    "net"
+    "net/url"
+    "time"
+)
+
+// probeTCPConnect checks a tcp://host:port target by opening a TCP connection
+// and closing it straight away. The reply time is the connect time only;
+// name resolution happens beforehand and isn't counted. A failed probe
+// tells whether the connection was refused or timed out.
+func probeTCPConnect(target string, fam addrFamily) pingResult {
+    u, err := url.Parse(target)
+    if err != nil {
+        return failed(failOther, "invalid target", "")
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+    defer cancel()
+    ip, err := resolveFor(ctx, u.Hostname(), fam)
+    if err != nil {
+        return failedErr(err, "")
+    }
+    var d net.Dialer
+    start := time.Now()
+    conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), u.Port()))
+    elapsed := time.Since(start)
+    if err != nil {
+        return failedErr(err, "")
+    }
+    conn.Close()
+    return pingResult{status: true, reply: float64(elapsed.Microseconds()) / 1000}
+}
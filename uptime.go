@@ -0,0 +1,116 @@
+package main
+
+import "time"
+
+// Uptime is the share of a host's rounds that found it UP or DEGRADED. Only
+// rounds mping actually ran count, so time mping wasn't running is neither
+// up nor down. The 1h window is kept per minute, the longer windows per
+// hour: they cover the current hour plus the full hours before it.
+
+// uptimeWindows are the windows uptime is reported over, in the order of
+// the uptimeStats fields.
+var uptimeWindows = []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
+
+// upCount counts rounds within one minute or hour. slot is that minute or
+// hour since the Unix epoch.
+type upCount struct {
+    slot       int64
+    rounds, up uint32
+}
+
+// uptime accumulates a host's rounds for the uptime columns.
+type uptime struct {
+    minutes [60]upCount
+    hours   [30 * 24]upCount
+}
+
+// add counts rounds taken at t, up of which found the host up.
+func (u *uptime) add(t time.Time, rounds, up int) {
+    count := func(b *upCount, slot int64) {
+        switch {
+        case b.slot > slot:
+            return // reused for a later slot already
+        case b.slot < slot:
+            *b = upCount{slot: slot}
+        }
+        b.rounds += uint32(rounds)
+        b.up += uint32(up)
+    }
+    min, hour := t.Unix()/60, t.Unix()/3600
+    count(&u.minutes[min%int64(len(u.minutes))], min)
+    count(&u.hours[hour%int64(len(u.hours))], hour)
+}
+
+// merge adds the rounds counted in o.
+func (u *uptime) merge(o *uptime) {
+    add := func(b *upCount, c upCount) {
+        switch {
+        case c.rounds == 0 || c.slot < b.slot:
+        case c.slot > b.slot:
+            *b = c
+        default:
+            b.rounds += c.rounds
+            b.up += c.up
+        }
+    }
+    for i, c := range o.minutes {
+        add(&u.minutes[i], c)
+    }
+    for i, c := range o.hours {
+        add(&u.hours[i], c)
+    }
+}
+
+// percent returns the uptime over window ending at now, and false if no
+// round was taken in it.
+func (u *uptime) percent(now time.Time, window time.Duration) (float64, bool) {
+    buckets, slot := u.hours[:], now.Unix()/3600
+    n := int64(window / time.Hour)
+    if window <= time.Hour {
+        buckets, slot, n = u.minutes[:], now.Unix()/60, int64(window/time.Minute)
+    }
+    var rounds, up uint64
+    for _, b := range buckets {
+        if b.slot > slot-n && b.slot <= slot {
+            rounds += uint64(b.rounds)
+            up += uint64(b.up)
+        }
+    }
+    if rounds == 0 {
+        return 0, false
+    }
+    return 100 * float64(up) / float64(rounds), true
+}
+
+// uptimeStats holds a host's uptime percentages, one per uptimeWindows entry.
+// known is false before its first round.
+type uptimeStats struct {
+    pct   [4]float64
+    known [4]bool
+}
+
+// stats computes the uptime over all uptimeWindows.
+func (u *uptime) stats(now time.Time) uptimeStats {
+    var st uptimeStats
+    for k, w := range uptimeWindows {
+        st.pct[k], st.known[k] = u.percent(now, w)
+    }
+    return st
+}
+
+// countUptime adds a finished round to the host's uptime and returns the
+// updated percentages.
+func (m *model) countUptime(id int, res pingResult, now time.Time) uptimeStats {
+    if m.uptime == nil {
+        m.uptime = make(map[int]*uptime)
+    }
+    if m.uptime[id] == nil {
+        m.uptime[id] = &uptime{}
+    }
+    up := 0
+    if res.up() {
+        up = 1
+    }
+    m.uptime[id].add(now, 1, up)
+    return m.uptime[id].stats(now)
+}
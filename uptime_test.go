@@ -0,0 +1,62 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestUptimePercent(t *testing.T) {
+    now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+    day := 24 * time.Hour
+    type round struct {
+        ago       time.Duration
+        rounds, up int
+    }
+    // loaded are rounds recorded before a restart, merged into the live ones.
+    // want lists the 1h, 24h, 7d and 30d uptime, "-" where unknown.
+    tests := []struct {
+        name         string
+        loaded, live []round
+        want         string
+    }{
+        {"empty", nil, nil, "- - - -"},
+        {"current minute", nil, []round{{0, 1, 1}}, "100 100 100 100"},
+        {"1h is the last 60 minutes", nil, []round{{59 * time.Minute, 1, 0}, {60 * time.Minute, 1, 1}}, "0 50 50 50"},
+        {"24h is the current hour and 23 full hours", nil,
+            []round{{23*time.Hour + 30*time.Minute, 1, 1}, {23*time.Hour + 31*time.Minute, 1, 0}}, "- 100 50 50"},
+        {"7d", nil, []round{{7*day - 31*time.Minute, 3, 2}, {7*day - 29*time.Minute, 1, 1}}, "- - 67 75"},
+        {"30d", nil, []round{{30*day - 31*time.Minute, 4, 4}, {30*day - 29*time.Minute, 2, 1}, {29 * day, 2, 1}}, "- - - 83"},
+        {"bucket of 30 days ago reused", nil, []round{{30 * day, 5, 0}, {0, 1, 1}}, "100 100 100 100"},
+        {"round of 30 days ago after reuse", nil, []round{{0, 1, 1}, {30 * day, 5, 0}}, "100 100 100 100"},
+        {"bucket of 30 days ago unused", nil, []round{{30 * day, 5, 0}}, "- - - -"},
+        {"future round", nil, []round{{-30 * time.Minute, 1, 0}, {0, 1, 1}}, "100 100 100 100"},
+        {"restart within the hour", []round{{40 * time.Minute, 10, 10}}, []round{{5 * time.Minute, 10, 0}}, "50 50 50 50"},
+        {"restart within the minute", []round{{10 * time.Second, 2, 2}}, []round{{5 * time.Second, 2, 0}}, "50 50 50 50"},
+        {"restart after an hour off", []round{{90 * time.Minute, 3, 3}}, []round{{10 * time.Minute, 1, 0}}, "0 75 75 75"},
+        {"loaded bucket reused live", []round{{30 * day, 5, 0}}, []round{{0, 1, 1}}, "100 100 100 100"},
+    }
+    for _, tt := range tests {
+        var u, loaded uptime
+        for _, r := range tt.loaded {
+            loaded.add(now.Add(-r.ago), r.rounds, r.up)
+        }
+        for _, r := range tt.live {
+            u.add(now.Add(-r.ago), r.rounds, r.up)
+        }
+        u.merge(&loaded)
+        var got []string
+        for _, w := range uptimeWindows {
+            pct, ok := u.percent(now, w)
+            if !ok {
+                got = append(got, "-")
+                continue
+            }
+            got = append(got, fmt.Sprintf("%.0f", pct))
+        }
+        if strings.Join(got, " ") != tt.want {
+            t.Errorf("%s: uptime %s, want %s", tt.name, strings.Join(got, " "), tt.want)
+        }
+    }
+}